@@ -1,16 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/api"
 	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
 	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/services"
 )
 
-var authToken = "API-TOKEN-SPEC"
-
 func main() {
 	// Load configuration from config.yaml
 	cfg, err := config.LoadConfig()
@@ -22,13 +27,88 @@ func main() {
 }
 
 func StartServer(cfg *config.Config) {
-	backendURL := url.URL{
-		Scheme: "http",
-		Host:   cfg.BackendHost + ":" + cfg.BackendPort,
+	var backendService services.Backend
+	var producer *services.KafkaProducer
+	if cfg.BackendMode == config.BackendModeMemory {
+		log.Println("Using the in-memory domain backend")
+		backendService = services.NewInMemoryBackend(services.SampleProducts()...)
+	} else {
+		var err error
+		producer, err = newKafkaProducer(cfg)
+		if err != nil {
+			log.Fatalf("Failed to set up the Kafka producer: %v", err)
+		}
+
+		backendURLs := make([]string, len(cfg.BackendEndpoints))
+		for i, endpoint := range cfg.BackendEndpoints {
+			backendURL := url.URL{
+				Scheme: cfg.BackendScheme(),
+				Host:   endpoint,
+			}
+			backendURLs[i] = backendURL.String()
+		}
+		credentials, err := services.NewCredentialsProvider(cfg)
+		if err != nil {
+			log.Fatalf("Failed to set up the domain API credentials: %v", err)
+		}
+
+		httpBackend, err := services.NewBackendService(cfg, backendURLs, credentials, producer)
+		if err != nil {
+			log.Fatalf("Failed to set up the domain API client: %v", err)
+		}
+		backendService = httpBackend
 	}
-	backendService := services.NewBackendService(backendURL.String(), authToken)
 
 	// setup router and start server
-	r := api.SetupRouter(backendService)
-	r.Run(":" + cfg.BFFServerPort)
+	r, err := api.SetupRouter(cfg, backendService)
+	if err != nil {
+		log.Fatalf("Failed to set up the router: %v", err)
+	}
+
+	server := &http.Server{Addr: ":" + cfg.BFFServerPort, Handler: r}
+	go func() {
+		log.Printf("Listening and serving HTTP on %s", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start the server: %v", err)
+		}
+	}()
+
+	// Stop accepting requests on SIGINT/SIGTERM and let the ones in flight finish before closing the producer.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+	log.Println("Shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Failed to shut down the server gracefully: %v", err)
+	}
+
+	if producer != nil {
+		if err := producer.Close(); err != nil {
+			log.Printf("Failed to flush and close the Kafka producer: %v", err)
+		}
+	}
+}
+
+// newKafkaProducer creates the producer shared by every request, making sure the broker is reachable first.
+func newKafkaProducer(cfg *config.Config) (*services.KafkaProducer, error) {
+	producer, err := services.NewKafkaProducer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := producer.Check(ctx); err != nil {
+		// With an outbox, events are kept until the broker is back, so it doesn't have to be up yet.
+		if producer.UsesOutbox() {
+			log.Printf("Kafka is not reachable, events will be kept in the outbox until it is: %v", err)
+			return producer, nil
+		}
+		producer.Close()
+		return nil, err
+	}
+	return producer, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/testcontainers/testcontainers-go/network"
 	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
@@ -12,6 +13,15 @@ import (
 
 var authToken = "API-TOKEN-SPEC"
 
+/**
+* The contract run lists products successfully twice, both times gadgets: the type the BFF defaults to. Every other
+* listing gets a 503 without publishing anything.
+ */
+const (
+	productListings   = 2
+	listedProductType = "gadget"
+)
+
 func TestContract(t *testing.T) {
 	env := setUpEnv(t)
 
@@ -38,10 +48,9 @@ func setUpEnv(t *testing.T) *test.TestEnvironment {
 	}
 
 	return &test.TestEnvironment{
-		Ctx:                  ctx,
-		Config:               config,
-		BffTestNetwork:       newNetwork,
-		ExpectedMessageCount: 2,
+		Ctx:            ctx,
+		Config:         config,
+		BffTestNetwork: newNetwork,
 	}
 }
 
@@ -54,6 +63,13 @@ func setUp(t *testing.T, env *test.TestEnvironment) {
 		t.Fatalf("could not start domain service container: %v", err)
 	}
 
+	// The BFF publishes one message per listed product, so the expectation follows what the stub returns.
+	productMessages, err := test.ProductMessagesPerListing(env, listedProductType)
+	if err != nil {
+		t.Fatalf("could not read the products of the domain stub: %v", err)
+	}
+	env.ExpectedMessageCount = productListings * productMessages
+
 	printHeader(t, 2, "Starting Kafka Mock")
 	env.KafkaServiceContainer, env.KafkaServiceDynamicPort, err = test.StartKafkaMock(t, env)
 	if err != nil {
@@ -83,6 +99,9 @@ func runTests(t *testing.T, env *test.TestEnvironment) {
 
 func tearDown(t *testing.T, env *test.TestEnvironment) {
 	if env.BffServiceContainer != nil {
+		if err := test.WaitForOutbox(env, 30*time.Second); err != nil {
+			t.Logf("Kafka messages were still pending in the BFF outbox: %v", err)
+		}
 		if err := env.BffServiceContainer.Terminate(env.Ctx); err != nil {
 			t.Logf("Failed to terminate BFF container: %v", err)
 		}
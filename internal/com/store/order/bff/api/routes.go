@@ -2,14 +2,24 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
 	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/handlers"
 	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/middleware"
 	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/services"
 )
 
-func SetupRouter(backendService *services.BackendService) *gin.Engine {
+func SetupRouter(cfg *config.Config, backendService services.Backend) (*gin.Engine, error) {
 	r := gin.Default()
 
+	// Validate requests against the BFF spec, when enabled
+	if cfg.RequestValidation {
+		requestValidator, err := middleware.NewRequestValidator(cfg.BFFSpecPath)
+		if err != nil {
+			return nil, err
+		}
+		r.Use(middleware.ValidateRequest(requestValidator))
+	}
+
 	productController := &handlers.ProductController{
 		BackendService: backendService,
 	}
@@ -18,15 +28,39 @@ func SetupRouter(backendService *services.BackendService) *gin.Engine {
 		BackendService: backendService,
 	}
 
+	idempotencyStore := middleware.NewIdempotencyStore(cfg.IdempotencyTTL, cfg.IdempotencyMaxEntries)
+
 	// Health check
 	r.GET("/health", handlers.HealthCheck)
 
+	// Backend status, only for backends that report it
+	if reporter, ok := backendService.(services.StatusReporter); ok {
+		statusController := &handlers.StatusController{
+			BackendService: reporter,
+		}
+		r.GET("/status/endpoints", statusController.Endpoints)
+		r.GET("/status/circuit-breakers", statusController.CircuitBreakers)
+		r.GET("/status/coalescing", statusController.Coalescing)
+		r.GET("/status/publishing", statusController.Publishing)
+	}
+
 	// Product routes
-	r.GET("/findAvailableProducts", middleware.RequirePageSize(), productController.FetchAvailableProducts)
-	r.POST("/products", productController.CreateProduct)
+	r.GET("/findAvailableProducts", middleware.RequirePageSize(cfg.MaxPageSize), productController.FetchAvailableProducts)
+	r.POST("/products", middleware.Idempotency(idempotencyStore), productController.CreateProduct)
+	r.GET("/products/:id", productController.GetProduct)
+	r.PUT("/products/:id", productController.ReplaceProduct)
+	r.PATCH("/products/:id", productController.PatchProduct)
+	r.DELETE("/products/:id", productController.DeleteProduct)
 
 	// Order routes
-	r.POST("/orders", orderController.CreateOrder)
+	r.GET("/orders", middleware.RequirePageSize(cfg.MaxPageSize), orderController.GetOrders)
+	r.POST("/orders", middleware.Idempotency(idempotencyStore), orderController.CreateOrder)
+	r.GET("/orders/:id", orderController.GetOrder)
+	r.POST("/orders/:id/cancel", orderController.CancelOrder)
+	r.POST("/orders/:id/fulfil", orderController.FulfilOrder)
+
+	// Checkout routes
+	r.POST("/checkout", middleware.Idempotency(idempotencyStore), orderController.Checkout)
 
-	return r
+	return r, nil
 }
@@ -1,10 +1,37 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	BackendModeHTTP   = "http"
+	BackendModeMemory = "memory"
+
+	LoadBalancingRoundRobin    = "round-robin"
+	LoadBalancingLeastInFlight = "least-in-flight"
+
+	CredentialsStatic = "static"
+	CredentialsOAuth2 = "oauth2"
+
+	PublishSync  = "sync"
+	PublishAsync = "async"
+
+	QueueFullBlock = "block"
+	QueueFullDrop  = "drop"
+	QueueFullFail  = "fail"
+
+	ResponseValidationOff    = "off"
+	ResponseValidationLog    = "log"
+	ResponseValidationReject = "reject"
 )
 
 type Config struct {
+	BackendMode   string
 	BackendPort   string
 	BackendHost   string
 	KafkaTopic    string
@@ -12,10 +39,112 @@ type Config struct {
 	KafkaHost     string
 	KafkaAPIPort  string
 	BFFServerPort string
+	MaxPageSize   int
+
+	// Topic receiving an event for every order created or cancelled through the BFF
+	KafkaOrderEventsTopic string
+
+	// Most product messages published for a single product listing
+	KafkaMaxProductMessages int
+
+	// Kafka publishing, either synchronous or through a bounded in-memory queue when async, with what to do when the
+	// queue is full. Batch size and linger apply to both, and the queue size also bounds the queue of order events.
+	KafkaPublishMode     string
+	KafkaQueueSize       int
+	KafkaQueueFullPolicy string
+	KafkaBatchSize       int
+	KafkaLinger          time.Duration
+
+	// Outbox keeping Kafka messages on disk until the broker acknowledges them, outbox.db by default except in async
+	// mode, disabled when the path is set empty
+	KafkaOutboxPath           string
+	KafkaOutboxBatchSize      int
+	KafkaOutboxPollInterval   time.Duration
+	KafkaOutboxInitialBackoff time.Duration
+	KafkaOutboxMaxBackoff     time.Duration
+	KafkaOutboxMaxAttempts    int
+
+	// How long in-flight requests get to finish on shutdown
+	ShutdownTimeout time.Duration
+
+	// host:port of every replica of the domain API, defaults to BackendHost:BackendPort
+	BackendEndpoints          []string
+	BackendLoadBalancing      string
+	BackendEjectAfterFailures int
+	BackendProbeInterval      time.Duration
+	BackendProbePath          string
+
+	// TLS to the domain API. The CA file is added to the system roots, the client certificate and key are both needed
+	// for mutual TLS, and pins are base64 SHA-256 hashes of the SubjectPublicKeyInfo of a certificate in the verified chain.
+	BackendTLS              bool
+	BackendCAFile           string
+	BackendClientCertFile   string
+	BackendClientKeyFile    string
+	BackendPinnedCertSHA256 []string
+
+	// Credentials sent to the domain API, either a static token (read from a file when one is given) or OAuth2 tokens
+	// from the client credentials grant, refreshed BackendOAuth2RefreshBefore ahead of their expiry.
+	BackendCredentials         string
+	BackendAuthToken           string
+	BackendAuthTokenFile       string
+	BackendOAuth2TokenURL      string
+	BackendOAuth2ClientID      string
+	BackendOAuth2ClientSecret  string
+	BackendOAuth2Scopes        []string
+	BackendOAuth2RefreshBefore time.Duration
+
+	// Validation of the domain API responses against a local copy of the consumed contract (api_order_v3.yaml)
+	BackendResponseValidation string
+	BackendContractPath       string
+
+	// Responses kept for replay, per Idempotency-Key, and the maximum number of keys kept at once
+	IdempotencyTTL        time.Duration
+	IdempotencyMaxEntries int
+
+	// Validation of incoming requests against the local copy of the spec the BFF provides (product_search_bff_v4.yaml),
+	// on by default
+	RequestValidation bool
+	BFFSpecPath       string
+
+	// Cache of the product lists returned by the domain API, disabled when the TTL is 0 (the default)
+	ProductCacheTTL      time.Duration
+	ProductCacheMaxStale time.Duration
+
+	// HTTP client used for every call to the domain API. GETs time out after BackendReadTimeout and other methods after
+	// BackendWriteTimeout, unless BackendOperationTimeouts has a timeout for the operation (e.g. "getOrders" -> 5s).
+	BackendReadTimeout         time.Duration
+	BackendWriteTimeout        time.Duration
+	BackendOperationTimeouts   map[string]time.Duration
+	BackendDialTimeout         time.Duration
+	BackendKeepAlive           time.Duration
+	BackendMaxIdleConns        int
+	BackendMaxIdleConnsPerHost int
+	BackendIdleConnTimeout     time.Duration
+
+	// Retry policy for calls to the domain API
+	BackendRetryMaxAttempts     int
+	BackendRetryInitialBackoff  time.Duration
+	BackendRetryMaxBackoff      time.Duration
+	BackendRetryJitter          float64
+	BackendRetryableStatusCodes []int
+
+	// Circuit breaker applied to every domain API operation
+	BackendBreakerFailureThreshold int
+	BackendBreakerOpenTimeout      time.Duration
+	BackendBreakerHalfOpenMaxCalls int
 }
 
 func LoadConfig() (*Config, error) {
+	env := &envReader{}
+
+	// The outbox is on unless async publishing is asked for, which can't be combined with it.
+	defaultOutboxPath := "outbox.db"
+	if getEnvOrDefault("KAFKA_PUBLISH_MODE", PublishSync) == PublishAsync {
+		defaultOutboxPath = ""
+	}
+
 	config := &Config{
+		BackendMode:   getEnvOrDefault("DOMAIN_SERVER_MODE", BackendModeHTTP),
 		BackendPort:   getEnvOrDefault("DOMAIN_SERVER_PORT", "9000"),
 		BackendHost:   getEnvOrDefault("DOMAIN_SERVER_HOST", "order-api-mock"),
 		KafkaTopic:    getEnvOrDefault("KAFKA_TOPIC", "product-queries"),
@@ -23,14 +152,291 @@ func LoadConfig() (*Config, error) {
 		KafkaHost:     getEnvOrDefault("KAFKA_HOST", "specmatic-kafka"),
 		KafkaAPIPort:  getEnvOrDefault("KAFKA_API_PORT", "9094"),
 		BFFServerPort: getEnvOrDefault("SERVER_PORT", "8080"),
+		MaxPageSize:   env.intOrDefault("MAX_PAGE_SIZE", 100),
+
+		KafkaOrderEventsTopic: getEnvOrDefault("KAFKA_ORDER_EVENTS_TOPIC", "order-events"),
+
+		KafkaMaxProductMessages: env.intOrDefault("KAFKA_MAX_PRODUCT_MESSAGES", 100),
+
+		KafkaPublishMode:     getEnvOrDefault("KAFKA_PUBLISH_MODE", PublishSync),
+		KafkaQueueSize:       env.intOrDefault("KAFKA_QUEUE_SIZE", 1000),
+		KafkaQueueFullPolicy: getEnvOrDefault("KAFKA_QUEUE_FULL_POLICY", QueueFullBlock),
+		KafkaBatchSize:       env.intOrDefault("KAFKA_BATCH_SIZE", 100),
+		KafkaLinger:          env.durationOrDefault("KAFKA_LINGER", 10*time.Millisecond),
+
+		KafkaOutboxPath:           getEnvOrDefault("KAFKA_OUTBOX_PATH", defaultOutboxPath),
+		KafkaOutboxBatchSize:      env.intOrDefault("KAFKA_OUTBOX_BATCH_SIZE", 100),
+		KafkaOutboxPollInterval:   env.durationOrDefault("KAFKA_OUTBOX_POLL_INTERVAL", time.Second),
+		KafkaOutboxInitialBackoff: env.durationOrDefault("KAFKA_OUTBOX_INITIAL_BACKOFF", 500*time.Millisecond),
+		KafkaOutboxMaxBackoff:     env.durationOrDefault("KAFKA_OUTBOX_MAX_BACKOFF", 30*time.Second),
+		KafkaOutboxMaxAttempts:    env.intOrDefault("KAFKA_OUTBOX_MAX_ATTEMPTS", 10),
+
+		ShutdownTimeout: env.durationOrDefault("SHUTDOWN_TIMEOUT", 15*time.Second),
+
+		BackendLoadBalancing:      getEnvOrDefault("DOMAIN_SERVER_LOAD_BALANCING", LoadBalancingRoundRobin),
+		BackendEjectAfterFailures: env.intOrDefault("DOMAIN_SERVER_EJECT_AFTER_FAILURES", 3),
+		BackendProbeInterval:      env.durationOrDefault("DOMAIN_SERVER_PROBE_INTERVAL", 5*time.Second),
+		BackendProbePath:          getEnvOrDefault("DOMAIN_SERVER_PROBE_PATH", "/"),
+
+		BackendTLS:              env.boolOrDefault("DOMAIN_SERVER_TLS", false),
+		BackendCAFile:           getEnvOrDefault("DOMAIN_SERVER_CA_FILE", ""),
+		BackendClientCertFile:   getEnvOrDefault("DOMAIN_SERVER_CLIENT_CERT_FILE", ""),
+		BackendClientKeyFile:    getEnvOrDefault("DOMAIN_SERVER_CLIENT_KEY_FILE", ""),
+		BackendPinnedCertSHA256: env.stringListOrDefault("DOMAIN_SERVER_PINNED_CERT_SHA256", nil),
+
+		BackendCredentials:         getEnvOrDefault("DOMAIN_SERVER_CREDENTIALS", CredentialsStatic),
+		BackendAuthToken:           getEnvOrDefault("DOMAIN_SERVER_AUTH_TOKEN", "API-TOKEN-SPEC"),
+		BackendAuthTokenFile:       getEnvOrDefault("DOMAIN_SERVER_AUTH_TOKEN_FILE", ""),
+		BackendOAuth2TokenURL:      getEnvOrDefault("DOMAIN_SERVER_OAUTH2_TOKEN_URL", ""),
+		BackendOAuth2ClientID:      getEnvOrDefault("DOMAIN_SERVER_OAUTH2_CLIENT_ID", ""),
+		BackendOAuth2ClientSecret:  getEnvOrDefault("DOMAIN_SERVER_OAUTH2_CLIENT_SECRET", ""),
+		BackendOAuth2Scopes:        env.stringListOrDefault("DOMAIN_SERVER_OAUTH2_SCOPES", nil),
+		BackendOAuth2RefreshBefore: env.durationOrDefault("DOMAIN_SERVER_OAUTH2_REFRESH_BEFORE", time.Minute),
+
+		BackendResponseValidation: getEnvOrDefault("DOMAIN_SERVER_RESPONSE_VALIDATION", ResponseValidationOff),
+		BackendContractPath:       getEnvOrDefault("DOMAIN_SERVER_CONTRACT_PATH", "contracts/api_order_v3.yaml"),
+
+		IdempotencyTTL:        env.durationOrDefault("IDEMPOTENCY_TTL", 24*time.Hour),
+		IdempotencyMaxEntries: env.intOrDefault("IDEMPOTENCY_MAX_ENTRIES", 10000),
+
+		RequestValidation: env.boolOrDefault("REQUEST_VALIDATION", true),
+		BFFSpecPath:       getEnvOrDefault("BFF_SPEC_PATH", "contracts/product_search_bff_v4.yaml"),
+
+		ProductCacheTTL:      env.durationOrDefault("PRODUCT_CACHE_TTL", 0),
+		ProductCacheMaxStale: env.durationOrDefault("PRODUCT_CACHE_MAX_STALE", 5*time.Minute),
+
+		BackendReadTimeout:         env.durationOrDefault("DOMAIN_SERVER_READ_TIMEOUT", 3*time.Second),
+		BackendWriteTimeout:        env.durationOrDefault("DOMAIN_SERVER_WRITE_TIMEOUT", 10*time.Second),
+		BackendOperationTimeouts:   env.durationMapOrDefault("DOMAIN_SERVER_OPERATION_TIMEOUTS", nil),
+		BackendDialTimeout:         env.durationOrDefault("DOMAIN_SERVER_DIAL_TIMEOUT", 2*time.Second),
+		BackendKeepAlive:           env.durationOrDefault("DOMAIN_SERVER_KEEP_ALIVE", 30*time.Second),
+		BackendMaxIdleConns:        env.intOrDefault("DOMAIN_SERVER_MAX_IDLE_CONNS", 100),
+		BackendMaxIdleConnsPerHost: env.intOrDefault("DOMAIN_SERVER_MAX_IDLE_CONNS_PER_HOST", 20),
+		BackendIdleConnTimeout:     env.durationOrDefault("DOMAIN_SERVER_IDLE_CONN_TIMEOUT", 90*time.Second),
+
+		BackendRetryMaxAttempts:     env.intOrDefault("DOMAIN_SERVER_RETRY_MAX_ATTEMPTS", 3),
+		BackendRetryInitialBackoff:  env.durationOrDefault("DOMAIN_SERVER_RETRY_INITIAL_BACKOFF", 100*time.Millisecond),
+		BackendRetryMaxBackoff:      env.durationOrDefault("DOMAIN_SERVER_RETRY_MAX_BACKOFF", 2*time.Second),
+		BackendRetryJitter:          env.floatOrDefault("DOMAIN_SERVER_RETRY_JITTER", 0.5),
+		BackendRetryableStatusCodes: env.intListOrDefault("DOMAIN_SERVER_RETRYABLE_STATUS_CODES", []int{502, 503, 504}),
+
+		BackendBreakerFailureThreshold: env.intOrDefault("DOMAIN_SERVER_BREAKER_FAILURE_THRESHOLD", 5),
+		BackendBreakerOpenTimeout:      env.durationOrDefault("DOMAIN_SERVER_BREAKER_OPEN_TIMEOUT", 30*time.Second),
+		BackendBreakerHalfOpenMaxCalls: env.intOrDefault("DOMAIN_SERVER_BREAKER_HALF_OPEN_MAX_CALLS", 1),
+	}
+
+	config.BackendEndpoints = env.stringListOrDefault("DOMAIN_SERVER_ENDPOINTS", []string{config.BackendHost + ":" + config.BackendPort})
+
+	if env.err != nil {
+		return nil, env.err
+	}
+
+	// "memory" replaces the domain API with an in-memory fake, for local development without the Specmatic stub.
+	if config.BackendMode != BackendModeHTTP && config.BackendMode != BackendModeMemory {
+		return nil, fmt.Errorf("DOMAIN_SERVER_MODE must be '%s' or '%s', got '%s'", BackendModeHTTP, BackendModeMemory, config.BackendMode)
+	}
+
+	if config.BackendLoadBalancing != LoadBalancingRoundRobin && config.BackendLoadBalancing != LoadBalancingLeastInFlight {
+		return nil, fmt.Errorf("DOMAIN_SERVER_LOAD_BALANCING must be '%s' or '%s', got '%s'", LoadBalancingRoundRobin, LoadBalancingLeastInFlight, config.BackendLoadBalancing)
+	}
+
+	if config.KafkaPublishMode != PublishSync && config.KafkaPublishMode != PublishAsync {
+		return nil, fmt.Errorf("KAFKA_PUBLISH_MODE must be '%s' or '%s', got '%s'", PublishSync, PublishAsync, config.KafkaPublishMode)
+	}
+
+	if config.KafkaQueueFullPolicy != QueueFullBlock && config.KafkaQueueFullPolicy != QueueFullDrop && config.KafkaQueueFullPolicy != QueueFullFail {
+		return nil, fmt.Errorf("KAFKA_QUEUE_FULL_POLICY must be '%s', '%s' or '%s', got '%s'", QueueFullBlock, QueueFullDrop, QueueFullFail, config.KafkaQueueFullPolicy)
+	}
+
+	if config.KafkaQueueSize <= 0 || config.KafkaBatchSize <= 0 || config.KafkaLinger <= 0 {
+		return nil, fmt.Errorf("KAFKA_QUEUE_SIZE, KAFKA_BATCH_SIZE and KAFKA_LINGER must be positive")
+	}
+
+	// The outbox already takes Kafka out of the request path, durably.
+	if config.KafkaPublishMode == PublishAsync && config.KafkaOutboxPath != "" {
+		return nil, fmt.Errorf("KAFKA_PUBLISH_MODE=%s can't be combined with KAFKA_OUTBOX_PATH", PublishAsync)
+	}
+
+	if config.KafkaOutboxPath != "" && (config.KafkaOutboxBatchSize <= 0 || config.KafkaOutboxPollInterval <= 0 || config.KafkaOutboxMaxAttempts <= 0) {
+		return nil, fmt.Errorf("KAFKA_OUTBOX_BATCH_SIZE, KAFKA_OUTBOX_POLL_INTERVAL and KAFKA_OUTBOX_MAX_ATTEMPTS must be positive")
+	}
+
+	if config.IdempotencyMaxEntries <= 0 {
+		return nil, fmt.Errorf("IDEMPOTENCY_MAX_ENTRIES must be positive, got %d", config.IdempotencyMaxEntries)
+	}
+
+	if config.BackendProbeInterval <= 0 {
+		return nil, fmt.Errorf("DOMAIN_SERVER_PROBE_INTERVAL must be positive, got %s", config.BackendProbeInterval)
+	}
+
+	if (config.BackendClientCertFile == "") != (config.BackendClientKeyFile == "") {
+		return nil, fmt.Errorf("DOMAIN_SERVER_CLIENT_CERT_FILE and DOMAIN_SERVER_CLIENT_KEY_FILE must be set together")
+	}
+
+	usesTLSSettings := config.BackendCAFile != "" || config.BackendClientCertFile != "" || len(config.BackendPinnedCertSHA256) > 0
+	if usesTLSSettings && !config.BackendTLS {
+		return nil, fmt.Errorf("DOMAIN_SERVER_TLS must be true to use a CA file, client certificate or certificate pins")
+	}
+
+	switch config.BackendResponseValidation {
+	case ResponseValidationOff, ResponseValidationLog, ResponseValidationReject:
+	default:
+		return nil, fmt.Errorf("DOMAIN_SERVER_RESPONSE_VALIDATION must be '%s', '%s' or '%s', got '%s'", ResponseValidationOff, ResponseValidationLog, ResponseValidationReject, config.BackendResponseValidation)
+	}
+
+	switch config.BackendCredentials {
+	case CredentialsStatic:
+	case CredentialsOAuth2:
+		if config.BackendOAuth2TokenURL == "" || config.BackendOAuth2ClientID == "" || config.BackendOAuth2ClientSecret == "" {
+			return nil, fmt.Errorf("DOMAIN_SERVER_OAUTH2_TOKEN_URL, DOMAIN_SERVER_OAUTH2_CLIENT_ID and DOMAIN_SERVER_OAUTH2_CLIENT_SECRET are required with oauth2 credentials")
+		}
+	default:
+		return nil, fmt.Errorf("DOMAIN_SERVER_CREDENTIALS must be '%s' or '%s', got '%s'", CredentialsStatic, CredentialsOAuth2, config.BackendCredentials)
 	}
 
 	return config, nil
 }
 
+// BackendScheme is the URL scheme used to reach the domain API.
+func (c *Config) BackendScheme() string {
+	if c.BackendTLS {
+		return "https"
+	}
+	return "http"
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
 	}
 	return defaultValue
 }
+
+// envReader parses typed environment variables, remembering the first invalid one so that LoadConfig can report it.
+type envReader struct {
+	err error
+}
+
+func (r *envReader) intOrDefault(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	intValue, err := strconv.Atoi(value)
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("%s must be a valid integer: %w", key, err)
+	}
+	return intValue
+}
+
+func (r *envReader) boolOrDefault(key string, defaultValue bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("%s must be true or false: %w", key, err)
+	}
+	return boolValue
+}
+
+func (r *envReader) durationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("%s must be a valid duration (e.g. 30s, 5m): %w", key, err)
+	}
+	return duration
+}
+
+func (r *envReader) floatOrDefault(key string, defaultValue float64) float64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("%s must be a valid number: %w", key, err)
+	}
+	return floatValue
+}
+
+// stringListOrDefault parses a comma separated list, e.g. "order-api-1:9000,order-api-2:9000".
+func (r *envReader) stringListOrDefault(key string, defaultValue []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	if len(values) == 0 && r.err == nil {
+		r.err = fmt.Errorf("%s must not be empty", key)
+	}
+	return values
+}
+
+// intListOrDefault parses a comma separated list of integers, e.g. "502,503,504".
+func (r *envReader) intListOrDefault(key string, defaultValue []int) []int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	var intValues []int
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		intValue, err := strconv.Atoi(item)
+		if err != nil {
+			if r.err == nil {
+				r.err = fmt.Errorf("%s must be a comma separated list of integers: %w", key, err)
+			}
+			return nil
+		}
+		intValues = append(intValues, intValue)
+	}
+	return intValues
+}
+
+// durationMapOrDefault parses a comma separated list of name=duration pairs, e.g. "getOrders=5s,createOrder=15s".
+func (r *envReader) durationMapOrDefault(key string, defaultValue map[string]time.Duration) map[string]time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	durations := make(map[string]time.Duration)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, durationStr, found := strings.Cut(item, "=")
+		duration, err := time.ParseDuration(strings.TrimSpace(durationStr))
+		if !found || err != nil || duration <= 0 {
+			if r.err == nil {
+				r.err = fmt.Errorf("%s must be a comma separated list of name=duration pairs with positive durations (e.g. getOrders=5s), got '%s'", key, item)
+			}
+			return nil
+		}
+		durations[strings.TrimSpace(name)] = duration
+	}
+	return durations
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/services"
+	"github.com/znsio/specmatic-order-bff-go/pkg/utils"
+)
+
+// statusCodeFor translates an error returned by the services to the status code sent back to the client.
+func statusCodeFor(err error) int {
+	switch {
+	case errors.Is(err, services.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrUnauthorized):
+		// The domain API rejected the credentials of the BFF, which the client can do nothing about.
+		return http.StatusBadGateway
+	case errors.Is(err, services.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrUpstreamUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func serviceErrorResponse(c *gin.Context, err error) {
+	utils.ErrorResponse(c, statusCodeFor(err), err.Error())
+}
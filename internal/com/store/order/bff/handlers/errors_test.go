@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/services"
+)
+
+func TestStatusCodeFor(t *testing.T) {
+	tests := []struct {
+		kind error
+		want int
+	}{
+		{services.ErrValidation, http.StatusBadRequest},
+		{services.ErrUnauthorized, http.StatusBadGateway},
+		{services.ErrNotFound, http.StatusNotFound},
+		{services.ErrConflict, http.StatusConflict},
+		{services.ErrUpstreamUnavailable, http.StatusServiceUnavailable},
+		{nil, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		err := fmt.Errorf("calling the domain API: %w", &services.ServiceError{Kind: tt.kind, Reason: "reason"})
+		if got := statusCodeFor(err); got != tt.want {
+			t.Errorf("statusCodeFor(%v) = %d, want %d", tt.kind, got, tt.want)
+		}
+	}
+
+	if got := statusCodeFor(errors.New("unexpected")); got != http.StatusInternalServerError {
+		t.Errorf("expected an unexpected error to be a 500, got %d", got)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -9,8 +10,9 @@ import (
 	"github.com/znsio/specmatic-order-bff-go/pkg/utils"
 )
 
+// OrderController needs the products too, to check the stock of a checkout as a whole.
 type OrderController struct {
-	BackendService *services.BackendService
+	BackendService services.Backend
 }
 
 func (oc *OrderController) CreateOrder(c *gin.Context) {
@@ -22,10 +24,10 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	// Call service to create the product
-	orderID, err := oc.BackendService.CreateOrder(newOrder)
+	// Call service to create the order
+	orderID, err := oc.BackendService.CreateOrder(c.Request.Context(), newOrder)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		serviceErrorResponse(c, err)
 		return
 	}
 
@@ -33,3 +35,97 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 		"id": orderID,
 	})
 }
+
+func (oc *OrderController) GetOrder(c *gin.Context) {
+	orderID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	order, err := oc.BackendService.GetOrder(c.Request.Context(), orderID)
+	if err != nil {
+		serviceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (oc *OrderController) GetOrders(c *gin.Context) {
+	var query models.OrderQuery
+
+	// Bind the optional 'status' and 'productid' filters from the query string.
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	pageRequest, ok := pageRequest(c)
+	if !ok {
+		return
+	}
+
+	page, err := oc.BackendService.GetOrders(c.Request.Context(), query, pageRequest)
+	if err != nil {
+		serviceErrorResponse(c, err)
+		return
+	}
+
+	setPageHeaders(c, page)
+	c.JSON(http.StatusOK, page.Items)
+}
+
+func (oc *OrderController) CancelOrder(c *gin.Context) {
+	oc.transitionOrder(c, models.OrderStatusCancelled)
+}
+
+func (oc *OrderController) FulfilOrder(c *gin.Context) {
+	oc.transitionOrder(c, models.OrderStatusFulfilled)
+}
+
+func (oc *OrderController) transitionOrder(c *gin.Context, next models.OrderStatus) {
+	orderID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	order, err := oc.BackendService.GetOrder(c.Request.Context(), orderID)
+	if err != nil {
+		serviceErrorResponse(c, err)
+		return
+	}
+
+	// Reject illegal transitions here, the domain API would accept any status.
+	if !order.Status.CanTransitionTo(next) {
+		utils.ErrorResponse(c, http.StatusConflict, fmt.Sprintf("order %d cannot move from '%s' to '%s'", order.ID, order.Status, next))
+		return
+	}
+
+	order.Status = next
+	err = oc.BackendService.UpdateOrder(c.Request.Context(), order)
+	if err != nil {
+		serviceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (oc *OrderController) Checkout(c *gin.Context) {
+	var checkoutRequest models.CheckoutRequest
+
+	// Bind JSON to CheckoutRequest Model (struct), every line item is validated like a single order request.
+	if err := c.ShouldBindJSON(&checkoutRequest); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	checkoutResponse, err := services.Checkout(c.Request.Context(), oc.BackendService, checkoutRequest.Items)
+	if err != nil {
+		// The per-line results are more useful to the client than a plain error response.
+		c.JSON(statusCodeFor(err), checkoutResponse)
+		return
+	}
+
+	c.JSON(http.StatusCreated, checkoutResponse)
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/middleware"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/services"
+)
+
+func newOrderRouter(backend *services.InMemoryBackend) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	orderController := &OrderController{BackendService: backend}
+	r.GET("/orders", middleware.RequirePageSize(20), orderController.GetOrders)
+	r.POST("/orders", orderController.CreateOrder)
+	r.GET("/orders/:id", orderController.GetOrder)
+	r.POST("/orders/:id/cancel", orderController.CancelOrder)
+	r.POST("/orders/:id/fulfil", orderController.FulfilOrder)
+	r.POST("/checkout", orderController.Checkout)
+	return r
+}
+
+func performRequest(r http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// getPage performs a GET request with the pageSize header set, leaving it out when pageSize is empty.
+func getPage(r http.Handler, path, pageSize string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if pageSize != "" {
+		req.Header.Set("pageSize", pageSize)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetOrders(t *testing.T) {
+	backend := services.NewInMemoryBackend(
+		models.Product{ID: 1, Name: "iPhone", Type: models.TypeGadget, Inventory: 10},
+		models.Product{ID: 2, Name: "Macbook", Type: models.TypeGadget, Inventory: 10},
+	)
+	for _, productID := range []int{1, 2, 1} {
+		if _, err := backend.CreateOrder(context.Background(), models.OrderRequest{ProductID: productID, Count: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancelled, _ := backend.GetOrder(context.Background(), 3)
+	cancelled.Status = models.OrderStatusCancelled
+	if err := backend.UpdateOrder(context.Background(), cancelled); err != nil {
+		t.Fatal(err)
+	}
+	r := newOrderRouter(backend)
+
+	tests := []struct {
+		name       string
+		path       string
+		pageSize   string
+		wantStatus int
+		wantIDs    []int
+	}{
+		{"all orders", "/orders", "10", http.StatusOK, []int{1, 2, 3}},
+		{"by status", "/orders?status=pending", "10", http.StatusOK, []int{1, 2}},
+		{"by product", "/orders?productid=1", "10", http.StatusOK, []int{1, 3}},
+		{"by status and product", "/orders?status=cancelled&productid=1", "10", http.StatusOK, []int{3}},
+		{"no match", "/orders?status=fulfilled", "10", http.StatusOK, []int{}},
+		{"second page", "/orders?page=2", "2", http.StatusOK, []int{3}},
+		{"invalid status", "/orders?status=shipped", "10", http.StatusBadRequest, nil},
+		{"negative product", "/orders?productid=-1", "10", http.StatusBadRequest, nil},
+		{"non numeric product", "/orders?productid=abc", "10", http.StatusBadRequest, nil},
+		{"missing pageSize", "/orders", "", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := getPage(r, tt.path, tt.pageSize)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantIDs == nil {
+				return
+			}
+
+			var orders []models.Order
+			if err := json.Unmarshal(w.Body.Bytes(), &orders); err != nil {
+				t.Fatal(err)
+			}
+			ids := []int{}
+			for _, order := range orders {
+				ids = append(ids, order.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.wantIDs) {
+				t.Fatalf("expected orders %v, got %v", tt.wantIDs, ids)
+			}
+		})
+	}
+}
+
+func TestCreateOrder(t *testing.T) {
+	backend := services.NewInMemoryBackend(models.Product{ID: 1, Name: "iPhone", Type: models.TypeGadget, Inventory: 2})
+	r := newOrderRouter(backend)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"in stock", `{"productid": 1, "count": 2}`, http.StatusCreated},
+		{"insufficient inventory", `{"productid": 1, "count": 3}`, http.StatusConflict},
+		{"unknown product", `{"productid": 99, "count": 1}`, http.StatusNotFound},
+		{"missing count", `{"productid": 1}`, http.StatusBadRequest},
+		{"negative count", `{"productid": 1, "count": -5}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := performRequest(r, http.MethodPost, "/orders", tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestOrderTransitions(t *testing.T) {
+	backend := services.NewInMemoryBackend(models.Product{ID: 1, Name: "iPhone", Type: models.TypeGadget, Inventory: 10})
+	orderID, err := backend.CreateOrder(context.Background(), models.OrderRequest{ProductID: 1, Count: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newOrderRouter(backend)
+
+	w := performRequest(r, http.MethodPost, "/orders/1/cancel", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected cancel to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	order, _ := backend.GetOrder(context.Background(), orderID)
+	if order.Status != models.OrderStatusCancelled {
+		t.Fatalf("expected order to be cancelled, got %s", order.Status)
+	}
+
+	w = performRequest(r, http.MethodPost, "/orders/1/fulfil", "")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected fulfilling a cancelled order to conflict, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = performRequest(r, http.MethodPost, "/orders/2/cancel", "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown order to be not found, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCheckoutRollsBackOnFailure(t *testing.T) {
+	backend := services.NewInMemoryBackend(
+		models.Product{ID: 1, Name: "iPhone", Type: models.TypeGadget, Inventory: 10},
+		models.Product{ID: 2, Name: "Macbook", Type: models.TypeGadget, Inventory: 1},
+	)
+	r := newOrderRouter(backend)
+
+	w := performRequest(r, http.MethodPost, "/checkout", `{"items": [{"productid": 1, "count": 1}, {"productid": 2, "count": 5}]}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+
+	var response models.CheckoutResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != models.CheckoutStatusFailed {
+		t.Fatalf("expected checkout to fail, got %s", response.Status)
+	}
+	if response.Lines[0].Status != models.CheckoutLineRolledBack || response.Lines[1].Status != models.CheckoutLineFailed {
+		t.Fatalf("unexpected line statuses: %+v", response.Lines)
+	}
+
+	for _, order := range backend.Orders() {
+		if order.Status != models.OrderStatusCancelled {
+			t.Fatalf("expected order %d to be cancelled by the rollback, got %s", order.ID, order.Status)
+		}
+	}
+}
+
+func TestCheckoutBackendUnavailable(t *testing.T) {
+	backend := services.NewInMemoryBackend(models.Product{ID: 1, Name: "iPhone", Type: models.TypeGadget, Inventory: 10})
+	backend.FailOn("createOrder", &services.ServiceError{Kind: services.ErrUpstreamUnavailable, Reason: "503 Service Unavailable", Err: errors.New("connection refused")})
+	r := newOrderRouter(backend)
+
+	w := performRequest(r, http.MethodPost, "/checkout", `{"items": [{"productid": 1, "count": 1}]}`)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusServiceUnavailable, w.Code, w.Body.String())
+	}
+}
+
+func TestCheckoutChecksCombinedInventory(t *testing.T) {
+	backend := services.NewInMemoryBackend(
+		models.Product{ID: 1, Name: "iPhone", Type: models.TypeGadget, Inventory: 3},
+		models.Product{ID: 2, Name: "Macbook", Type: models.TypeGadget, Inventory: 10},
+	)
+	r := newOrderRouter(backend)
+
+	w := performRequest(r, http.MethodPost, "/checkout", `{"items": [{"productid": 1, "count": 3}, {"productid": 2, "count": 1}, {"productid": 1, "count": 1}]}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+
+	var response models.CheckoutResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Lines[0].Status != models.CheckoutLineFailed || response.Lines[1].Status != models.CheckoutLineSkipped || response.Lines[2].Status != models.CheckoutLineFailed {
+		t.Fatalf("unexpected line statuses: %+v", response.Lines)
+	}
+	if len(backend.Orders()) != 0 {
+		t.Fatalf("expected no order to be created, got %+v", backend.Orders())
+	}
+}
+
+func TestCheckoutLimitsItems(t *testing.T) {
+	r := newOrderRouter(services.NewInMemoryBackend(models.Product{ID: 1, Name: "iPhone", Type: models.TypeGadget, Inventory: 100}))
+
+	items := strings.TrimSuffix(strings.Repeat(`{"productid": 1, "count": 1},`, 51), ",")
+	w := performRequest(r, http.MethodPost, "/checkout", `{"items": [`+items+`]}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
+	"github.com/znsio/specmatic-order-bff-go/pkg/utils"
+)
+
+// pageRequest builds the page request from the 'page' query parameter and the 'pageSize' set by the RequirePageSize
+// middleware. On failure the error response has already been written and false is returned.
+func pageRequest(c *gin.Context) (models.PageRequest, bool) {
+	pageSize, exists := c.Get("pageSize")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "pageSize not found in context")
+		return models.PageRequest{}, false
+	}
+
+	page := 1
+	if pageStr := c.Query("page"); pageStr != "" {
+		var err error
+		page, err = strconv.Atoi(pageStr)
+		if err != nil || page <= 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "page must be a positive integer")
+			return models.PageRequest{}, false
+		}
+	}
+
+	return models.PageRequest{Page: page, PageSize: pageSize.(int)}, true
+}
+
+// setPageHeaders exposes the pagination details of a page through the X-Total-Count and Link (RFC 8288) headers, so
+// the response body can remain a plain json array.
+func setPageHeaders[T any](c *gin.Context, page models.Page[T]) {
+	c.Header("X-Total-Count", strconv.Itoa(page.Total))
+
+	lastPage := page.TotalPages()
+	if lastPage == 0 {
+		return
+	}
+
+	links := []string{
+		pageLink(c, 1, "first"),
+	}
+	if page.Page > 1 && page.Page <= lastPage {
+		links = append(links, pageLink(c, page.Page-1, "prev"))
+	}
+	if page.Page < lastPage {
+		links = append(links, pageLink(c, page.Page+1, "next"))
+	}
+	links = append(links, pageLink(c, lastPage, "last"))
+
+	c.Header("Link", strings.Join(links, ", "))
+}
+
+func pageLink(c *gin.Context, page int, rel string) string {
+	query := c.Request.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	return fmt.Sprintf(`<%s?%s>; rel="%s"`, c.Request.URL.Path, query.Encode(), rel)
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/middleware"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
+)
+
+// newPageRouter pages through 5 items on GET /items, with a maximum page size of 3.
+func newPageRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	r.GET("/items", middleware.RequirePageSize(3), func(c *gin.Context) {
+		pageRequest, ok := pageRequest(c)
+		if !ok {
+			return
+		}
+		page := models.Paginate([]int{1, 2, 3, 4, 5}, pageRequest)
+		setPageHeaders(c, page)
+		c.JSON(http.StatusOK, page.Items)
+	})
+	return r
+}
+
+func TestPagination(t *testing.T) {
+	r := newPageRouter()
+
+	tests := []struct {
+		name       string
+		path       string
+		pageSize   string
+		wantStatus int
+		wantBody   string
+		wantLink   string
+	}{
+		{
+			name: "first page", path: "/items?type=book", pageSize: "2", wantStatus: http.StatusOK, wantBody: "[1,2]",
+			wantLink: `</items?page=1&type=book>; rel="first", </items?page=2&type=book>; rel="next", </items?page=3&type=book>; rel="last"`,
+		},
+		{
+			name: "middle page", path: "/items?page=2", pageSize: "2", wantStatus: http.StatusOK, wantBody: "[3,4]",
+			wantLink: `</items?page=1>; rel="first", </items?page=1>; rel="prev", </items?page=3>; rel="next", </items?page=3>; rel="last"`,
+		},
+		{
+			name: "last page", path: "/items?page=3", pageSize: "2", wantStatus: http.StatusOK, wantBody: "[5]",
+			wantLink: `</items?page=1>; rel="first", </items?page=2>; rel="prev", </items?page=3>; rel="last"`,
+		},
+		{
+			name: "page past the end", path: "/items?page=4", pageSize: "2", wantStatus: http.StatusOK, wantBody: "[]",
+			wantLink: `</items?page=1>; rel="first", </items?page=3>; rel="last"`,
+		},
+		{
+			name: "page size at the maximum", path: "/items", pageSize: "3", wantStatus: http.StatusOK, wantBody: "[1,2,3]",
+			wantLink: `</items?page=1>; rel="first", </items?page=2>; rel="next", </items?page=2>; rel="last"`,
+		},
+		{name: "page size over the maximum", path: "/items", pageSize: "4", wantStatus: http.StatusBadRequest},
+		{name: "page size zero", path: "/items", pageSize: "0", wantStatus: http.StatusBadRequest},
+		{name: "page size not a number", path: "/items", pageSize: "two", wantStatus: http.StatusBadRequest},
+		{name: "missing page size", path: "/items", wantStatus: http.StatusBadRequest},
+		{name: "page zero", path: "/items?page=0", pageSize: "2", wantStatus: http.StatusBadRequest},
+		{name: "page not a number", path: "/items?page=last", pageSize: "2", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := getPage(r, tt.path, tt.pageSize)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if w.Body.String() != tt.wantBody {
+				t.Fatalf("expected body %s, got %s", tt.wantBody, w.Body.String())
+			}
+			if got := w.Header().Get("X-Total-Count"); got != "5" {
+				t.Fatalf("expected X-Total-Count 5, got %q", got)
+			}
+			if got := w.Header().Get("Link"); got != tt.wantLink {
+				t.Fatalf("expected Link\n%s\ngot\n%s", tt.wantLink, got)
+			}
+		})
+	}
+}
+
+func TestPaginationOfEmptyResult(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/items", middleware.RequirePageSize(3), func(c *gin.Context) {
+		pageRequest, _ := pageRequest(c)
+		setPageHeaders(c, models.Paginate([]int{}, pageRequest))
+		c.Status(http.StatusOK)
+	})
+
+	w := getPage(r, "/items", "2")
+	if w.Header().Get("X-Total-Count") != "0" || w.Header().Get("Link") != "" {
+		t.Fatalf("expected a zero count without links, got %v", w.Header())
+	}
+}
+
+func TestPageRequestWithoutPageSizeMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/items", nil)
+
+	if _, ok := pageRequest(c); ok {
+		t.Fatal("expected the page request to fail without a pageSize in the context")
+	}
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/znsio/specmatic-order-bff-go/pkg/utils"
+)
+
+// pathID reads the ':id' path parameter, writing a 400 response and returning false when it is not a positive integer.
+func pathID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "id must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
@@ -10,32 +10,31 @@ import (
 )
 
 type ProductController struct {
-	BackendService *services.BackendService
+	BackendService services.ProductBackend
 }
 
 func (pc *ProductController) FetchAvailableProducts(c *gin.Context) {
 	// Get the 'type' query parameter, default to "gadget" if not provided
 	productType := c.DefaultQuery("type", "gadget")
 
-	// Get the 'pageSize' from the context (set by the middleware, post validation)
-	pageSize, exists := c.Get("pageSize")
-	if !exists {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "pageSize not found in context")
+	pageRequest, ok := pageRequest(c)
+	if !ok {
 		return
 	}
 
-	if pageSize.(int) == 20 || productType == "other" {
+	if pageRequest.PageSize == 20 || productType == "other" {
 		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Service Unavailable")
 		return
 	}
 
-	products, errorCode, err := pc.BackendService.GetAllProducts(productType, pageSize.(int))
+	page, err := pc.BackendService.GetAllProducts(c.Request.Context(), productType, pageRequest)
 	if err != nil {
-		utils.ErrorResponse(c, errorCode, err.Error())
+		serviceErrorResponse(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, products)
+	setPageHeaders(c, page)
+	c.JSON(http.StatusOK, page.Items)
 }
 
 func (pc *ProductController) CreateProduct(c *gin.Context) {
@@ -48,9 +47,9 @@ func (pc *ProductController) CreateProduct(c *gin.Context) {
 	}
 
 	// Call service to create the product
-	productID, err := pc.BackendService.CreateProduct(newProduct)
+	productID, err := pc.BackendService.CreateProduct(c.Request.Context(), newProduct)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		serviceErrorResponse(c, err)
 		return
 	}
 
@@ -58,3 +57,86 @@ func (pc *ProductController) CreateProduct(c *gin.Context) {
 		"id": productID,
 	})
 }
+
+func (pc *ProductController) GetProduct(c *gin.Context) {
+	productID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	product, err := pc.BackendService.GetProduct(c.Request.Context(), productID)
+	if err != nil {
+		serviceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+func (pc *ProductController) ReplaceProduct(c *gin.Context) {
+	productID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	var newProduct models.NewProduct
+
+	// Bind JSON to NewProduct Model (struct), a replacement has the same rules as a creation.
+	if err := c.ShouldBindJSON(&newProduct); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	product := newProduct.ToProduct(productID)
+	err := pc.BackendService.UpdateProduct(c.Request.Context(), product)
+	if err != nil {
+		serviceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+func (pc *ProductController) PatchProduct(c *gin.Context) {
+	productID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	var patch models.ProductPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The domain API only supports full updates, so merge the patch into the current product first.
+	product, err := pc.BackendService.GetProduct(c.Request.Context(), productID)
+	if err != nil {
+		serviceErrorResponse(c, err)
+		return
+	}
+
+	patch.ApplyTo(&product)
+	err = pc.BackendService.UpdateProduct(c.Request.Context(), product)
+	if err != nil {
+		serviceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+func (pc *ProductController) DeleteProduct(c *gin.Context) {
+	productID, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	err := pc.BackendService.DeleteProduct(c.Request.Context(), productID)
+	if err != nil {
+		serviceErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/services"
+)
+
+func newProductRouter(backend *services.InMemoryBackend) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	productController := &ProductController{BackendService: backend}
+	r.POST("/products", productController.CreateProduct)
+	r.GET("/products/:id", productController.GetProduct)
+	r.PUT("/products/:id", productController.ReplaceProduct)
+	r.PATCH("/products/:id", productController.PatchProduct)
+	r.DELETE("/products/:id", productController.DeleteProduct)
+	return r
+}
+
+func TestProductLifecycle(t *testing.T) {
+	backend := services.NewInMemoryBackend()
+	r := newProductRouter(backend)
+
+	w := performRequest(r, http.MethodPost, "/products", `{"name": "iPhone", "type": "gadget", "inventory": "10"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	w = performRequest(r, http.MethodPatch, "/products/1", `{"inventory": "7"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var product models.Product
+	if err := json.Unmarshal(w.Body.Bytes(), &product); err != nil {
+		t.Fatal(err)
+	}
+	if product.Name != "iPhone" || product.Inventory != 7 {
+		t.Fatalf("expected patch to only change the inventory, got %+v", product)
+	}
+
+	w = performRequest(r, http.MethodDelete, "/products/1", "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+
+	w = performRequest(r, http.MethodGet, "/products/1", "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNotFound, w.Code, w.Body.String())
+	}
+}
+
+func TestReplaceProductValidation(t *testing.T) {
+	backend := services.NewInMemoryBackend(models.Product{ID: 1, Name: "iPhone", Type: models.TypeGadget, Inventory: 10})
+	r := newProductRouter(backend)
+
+	w := performRequest(r, http.MethodPut, "/products/1", `{"name": "iPhone", "type": "car", "inventory": 10}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+}
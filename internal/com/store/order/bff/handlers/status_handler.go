@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/services"
+)
+
+type StatusController struct {
+	BackendService services.StatusReporter
+}
+
+func (sc *StatusController) Endpoints(c *gin.Context) {
+	c.JSON(http.StatusOK, sc.BackendService.Endpoints())
+}
+
+func (sc *StatusController) CircuitBreakers(c *gin.Context) {
+	c.JSON(http.StatusOK, sc.BackendService.CircuitBreakers())
+}
+
+func (sc *StatusController) Coalescing(c *gin.Context) {
+	c.JSON(http.StatusOK, sc.BackendService.CoalescingStats())
+}
+
+func (sc *StatusController) Publishing(c *gin.Context) {
+	c.JSON(http.StatusOK, sc.BackendService.PublishStats())
+}
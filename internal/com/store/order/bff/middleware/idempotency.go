@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/services"
+	"github.com/znsio/specmatic-order-bff-go/pkg/utils"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type idempotencyEntry struct {
+	requestHash string
+	inFlight    bool
+	statusCode  int
+	contentType string
+	body        []byte
+	expiresAt   time.Time
+	completed   *list.Element
+}
+
+/**
+* IdempotencyStore keeps the first response for each idempotency key in memory for the configured TTL, for at most
+* maxEntries keys. Completed entries are kept in the order they completed, which is also the order they expire in, so
+* expired entries are dropped from the front and the oldest one is evicted when a new key finds the store full. When
+* every entry is still in flight, the new key is refused.
+ */
+type IdempotencyStore struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*idempotencyEntry
+	completed  *list.List
+}
+
+func NewIdempotencyStore(ttl time.Duration, maxEntries int) *IdempotencyStore {
+	return &IdempotencyStore{ttl: ttl, maxEntries: maxEntries, entries: make(map[string]*idempotencyEntry), completed: list.New()}
+}
+
+/**
+* begin returns the entry already stored for the key, or reserves the key for a new in-flight request and returns nil.
+* It returns false when the key is new and the store is full of requests still in flight.
+ */
+func (s *IdempotencyStore) begin(key, requestHash string) (*idempotencyEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for front := s.completed.Front(); front != nil && now.After(s.entries[front.Value.(string)].expiresAt); front = s.completed.Front() {
+		s.remove(front.Value.(string))
+	}
+
+	if entry, exists := s.entries[key]; exists {
+		copied := *entry
+		return &copied, true
+	}
+
+	if len(s.entries) >= s.maxEntries {
+		oldest := s.completed.Front()
+		if oldest == nil {
+			return nil, false
+		}
+		s.remove(oldest.Value.(string))
+	}
+
+	s.entries[key] = &idempotencyEntry{requestHash: requestHash, inFlight: true}
+	return nil, true
+}
+
+func (s *IdempotencyStore) complete(key string, statusCode int, contentType string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Server errors are not stored so that the client can retry them with the same key.
+	if statusCode >= http.StatusInternalServerError {
+		s.remove(key)
+		return
+	}
+
+	entry, exists := s.entries[key]
+	if !exists || !entry.inFlight {
+		return
+	}
+	entry.inFlight = false
+	entry.statusCode = statusCode
+	entry.contentType = contentType
+	entry.body = body
+	entry.expiresAt = time.Now().Add(s.ttl)
+	entry.completed = s.completed.PushBack(key)
+}
+
+// release forgets a request that did not complete, so that it can be retried with the same key.
+func (s *IdempotencyStore) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, exists := s.entries[key]; exists && entry.inFlight {
+		s.remove(key)
+	}
+}
+
+// remove must be called with the lock held.
+func (s *IdempotencyStore) remove(key string) {
+	entry, exists := s.entries[key]
+	if !exists {
+		return
+	}
+	if entry.completed != nil {
+		s.completed.Remove(entry.completed)
+	}
+	delete(s.entries, key)
+}
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+/**
+* Idempotency replays the stored response when a request is retried with the same Idempotency-Key header. Keys are
+* scoped to the method and path, and reusing a key with a different request body is rejected with 422. Requests
+* without the header are passed through untouched.
+ */
+func Idempotency(store *IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+
+		requestBody, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "could not read request body")
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(requestBody))
+
+		hash := sha256.Sum256(requestBody)
+		requestHash := hex.EncodeToString(hash[:])
+		key := c.Request.Method + " " + c.FullPath() + " " + idempotencyKey
+
+		entry, ok := store.begin(key, requestHash)
+		if !ok {
+			c.Header("Retry-After", "1")
+			utils.ErrorResponse(c, http.StatusServiceUnavailable, "too many requests with an Idempotency-Key are being processed, retry later")
+			c.Abort()
+			return
+		}
+		if entry != nil {
+			switch {
+			case entry.requestHash != requestHash:
+				utils.ErrorResponse(c, http.StatusUnprocessableEntity, "Idempotency-Key has already been used with a different request body")
+			case entry.inFlight:
+				utils.ErrorResponse(c, http.StatusConflict, "a request with this Idempotency-Key is still being processed")
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(entry.statusCode, entry.contentType, entry.body)
+			}
+			c.Abort()
+			return
+		}
+
+		// Let the backend calls know the request can safely be retried and forward the key to the domain API.
+		c.Request = c.Request.WithContext(services.WithIdempotencyKey(c.Request.Context(), idempotencyKey))
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// A panicking handler never completes the request, the key is released while the panic unwinds.
+		completed := false
+		defer func() {
+			if !completed {
+				store.release(key)
+			}
+		}()
+
+		c.Next()
+
+		store.complete(key, recorder.Status(), recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+		completed = true
+	}
+}
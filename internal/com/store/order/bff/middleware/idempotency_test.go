@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newIdempotentRouter serves POST /orders, answering with the status set on the "status" query parameter.
+func newIdempotentRouter(calls *atomic.Int32, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.RecoveryWithWriter(io.Discard))
+
+	if handler == nil {
+		handler = func(c *gin.Context) {
+			status := http.StatusCreated
+			if c.Query("status") == "503" {
+				status = http.StatusServiceUnavailable
+			}
+			c.JSON(status, gin.H{"id": calls.Add(1)})
+		}
+	}
+	r.POST("/orders", Idempotency(NewIdempotencyStore(time.Minute, 10)), handler)
+	return r
+}
+
+func postWithKey(r *gin.Engine, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysFirstResponse(t *testing.T) {
+	var calls atomic.Int32
+	r := newIdempotentRouter(&calls, nil)
+
+	first := postWithKey(r, "/orders", "key-1", `{"productid":1,"count":1}`)
+	retry := postWithKey(r, "/orders", "key-1", `{"productid":1,"count":1}`)
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected the handler to be called once, got %d", calls.Load())
+	}
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+		t.Fatalf("expected the retry to replay %d %s, got %d %s", first.Code, first.Body, retry.Code, retry.Body)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("expected the retry to be marked as replayed")
+	}
+}
+
+func TestIdempotencyRejectsKeyReusedWithDifferentBody(t *testing.T) {
+	var calls atomic.Int32
+	r := newIdempotentRouter(&calls, nil)
+
+	postWithKey(r, "/orders", "key-1", `{"productid":1,"count":1}`)
+	w := postWithKey(r, "/orders", "key-1", `{"productid":1,"count":2}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+}
+
+func TestIdempotencyRejectsRetryWhileInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r := newIdempotentRouter(nil, func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusCreated)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		postWithKey(r, "/orders", "key-1", `{}`)
+	}()
+	<-started
+
+	w := postWithKey(r, "/orders", "key-1", `{}`)
+	close(release)
+	<-done
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	r := newIdempotentRouter(&calls, nil)
+
+	postWithKey(r, "/orders?status=503", "key-1", `{}`)
+	w := postWithKey(r, "/orders?status=503", "key-1", `{}`)
+
+	if calls.Load() != 2 || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("expected the server error not to be replayed, got %d calls", calls.Load())
+	}
+}
+
+func TestIdempotencyReleasesKeyWhenHandlerPanics(t *testing.T) {
+	var calls atomic.Int32
+	r := newIdempotentRouter(nil, func(c *gin.Context) {
+		if calls.Add(1) == 1 {
+			panic("boom")
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	if w := postWithKey(r, "/orders", "key-1", `{}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected the panic to be recovered as a 500, got %d", w.Code)
+	}
+	if w := postWithKey(r, "/orders", "key-1", `{}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected the key to be usable again after the panic, got %d", w.Code)
+	}
+}
+
+func TestIdempotencyStoreIgnoresExpiredEntries(t *testing.T) {
+	store := NewIdempotencyStore(time.Millisecond, 10)
+	store.begin("key-1", "hash")
+	store.complete("key-1", http.StatusCreated, "application/json", []byte(`{}`))
+
+	time.Sleep(5 * time.Millisecond)
+	if entry, _ := store.begin("key-1", "other-hash"); entry != nil {
+		t.Fatalf("expected the expired entry to be ignored, got %+v", entry)
+	}
+	if len(store.entries) != 1 || store.completed.Len() != 0 {
+		t.Fatalf("expected the expired entry to be removed, got %d entries", len(store.entries))
+	}
+}
+
+func TestIdempotencyStoreEvictsOldestCompletedEntry(t *testing.T) {
+	store := NewIdempotencyStore(time.Minute, 3)
+	for _, key := range []string{"key-1", "key-2", "key-3"} {
+		store.begin(key, "hash")
+	}
+	store.complete("key-2", http.StatusCreated, "application/json", []byte(`{}`))
+	store.complete("key-1", http.StatusCreated, "application/json", []byte(`{}`))
+
+	if _, ok := store.begin("key-4", "hash"); !ok {
+		t.Fatal("expected a completed entry to make room for the new key")
+	}
+	if len(store.entries) != 3 {
+		t.Fatalf("expected the store to stay at 3 entries, got %d", len(store.entries))
+	}
+	if _, exists := store.entries["key-2"]; exists {
+		t.Fatal("expected the entry completed first to be evicted")
+	}
+	if _, exists := store.entries["key-1"]; !exists {
+		t.Fatal("expected the entry completed last to be kept")
+	}
+}
+
+func TestIdempotencyRefusesNewKeysWhenFullOfRequestsInFlight(t *testing.T) {
+	store := NewIdempotencyStore(time.Minute, 1)
+	store.begin("POST /orders key-1", "hash")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/orders", Idempotency(store), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	w := postWithKey(r, "/orders", "key-2", `{}`)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected a 503 with Retry-After, got %d", w.Code)
+	}
+
+	store.complete("POST /orders key-1", http.StatusCreated, "application/json", []byte(`{}`))
+	if w := postWithKey(r, "/orders", "key-2", `{}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected the key to be accepted once the other request completed, got %d", w.Code)
+	}
+}
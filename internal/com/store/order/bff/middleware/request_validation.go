@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+	"github.com/znsio/specmatic-order-bff-go/pkg/utils"
+)
+
+// RequestValidator checks incoming requests against the OpenAPI spec the BFF provides (product_search_bff_v4.yaml).
+type RequestValidator struct {
+	router routers.Router
+}
+
+func NewRequestValidator(specPath string) (*RequestValidator, error) {
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+
+	doc, err := loader.LoadFromFile(specPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading BFF spec %s: %w", specPath, err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("invalid BFF spec %s: %w", specPath, err)
+	}
+
+	// Requests are matched on their path only, whatever host the BFF is reached through.
+	doc.Servers = nil
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("error routing BFF spec %s: %w", specPath, err)
+	}
+
+	return &RequestValidator{router: router}, nil
+}
+
+/**
+* ValidateRequest rejects requests whose path parameters, query, headers or body don't match the spec, with a 400
+* listing every violation. Routes the spec doesn't describe are let through untouched, as are authentication
+* requirements, which are left to the handlers.
+ */
+func ValidateRequest(validator *RequestValidator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, pathParams, err := validator.router.FindRoute(c.Request)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		err = openapi3filter.ValidateRequest(c.Request.Context(), &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: pathParams,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				MultiError:          true,
+				SkipSettingDefaults: true,
+				AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+			},
+		})
+		if err != nil {
+			utils.ValidationErrorResponse(c, "The request does not match the API specification", fieldErrors(err, "", ""))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// fieldErrors flattens the errors of kin-openapi into one entry per invalid parameter or body field.
+func fieldErrors(err error, in, name string) []utils.FieldError {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		var result []utils.FieldError
+		for _, inner := range e {
+			result = append(result, fieldErrors(inner, in, name)...)
+		}
+		return result
+
+	case *openapi3filter.RequestError:
+		switch {
+		case e.Parameter != nil:
+			in, name = e.Parameter.In, e.Parameter.Name
+		case e.RequestBody != nil:
+			in = "body"
+		}
+		if e.Err == nil {
+			return []utils.FieldError{{In: in, Name: name, Reason: e.Reason}}
+		}
+		return fieldErrors(e.Err, in, name)
+
+	case *openapi3.SchemaError:
+		// Body fields are named by their JSON pointer, parameters keep their own name.
+		if pointer := e.JSONPointer(); len(pointer) > 0 && in == "body" {
+			name = "/" + strings.Join(pointer, "/")
+		}
+		return []utils.FieldError{{In: in, Name: name, Reason: e.Reason}}
+
+	default:
+		return []utils.FieldError{{In: in, Name: name, Reason: err.Error()}}
+	}
+}
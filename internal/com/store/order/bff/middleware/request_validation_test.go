@@ -0,0 +1,205 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/znsio/specmatic-order-bff-go/pkg/utils"
+)
+
+const testBFFSpec = `
+openapi: 3.0.0
+info:
+  title: Product search BFF
+  version: "4"
+servers:
+  - url: http://localhost:8080
+paths:
+  /findAvailableProducts:
+    get:
+      parameters:
+        - name: type
+          in: query
+          schema:
+            type: string
+            enum: [gadget, book, food, other]
+        - name: pageSize
+          in: header
+          required: true
+          schema:
+            type: integer
+      responses:
+        "200":
+          description: Products
+  /products:
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [name, type, inventory]
+              properties:
+                name:
+                  type: string
+                type:
+                  type: string
+                inventory:
+                  type: integer
+                  minimum: 1
+      responses:
+        "201":
+          description: Created
+`
+
+func newValidatedRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	specPath := filepath.Join(t.TempDir(), "product_search_bff_v4.yaml")
+	if err := os.WriteFile(specPath, []byte(testBFFSpec), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	validator, err := NewRequestValidator(specPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Use(ValidateRequest(validator))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/findAvailableProducts", ok)
+	r.POST("/products", ok)
+	r.GET("/health", ok)
+	return r
+}
+
+func validate(r *gin.Engine, req *http.Request) (int, []utils.FieldError) {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body struct {
+		Errors []utils.FieldError `json:"errors"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body.Errors
+}
+
+func TestValidateRequestRejectsInvalidParameters(t *testing.T) {
+	r := newValidatedRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/findAvailableProducts?type=toy", nil)
+	code, fieldErrors := validate(r, req)
+
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", code)
+	}
+	got := map[string]bool{}
+	for _, e := range fieldErrors {
+		got[e.In+":"+e.Name] = true
+	}
+	if !got["query:type"] || !got["header:pageSize"] || len(fieldErrors) != 2 {
+		t.Fatalf("expected errors on query type and header pageSize, got %+v", fieldErrors)
+	}
+}
+
+func TestValidateRequestRejectsInvalidBody(t *testing.T) {
+	r := newValidatedRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(`{"name":"iPhone","type":"gadget","inventory":0}`))
+	req.Header.Set("Content-Type", "application/json")
+	code, fieldErrors := validate(r, req)
+
+	if code != http.StatusBadRequest || len(fieldErrors) != 1 || fieldErrors[0].In != "body" || fieldErrors[0].Name != "/inventory" {
+		t.Fatalf("expected a 400 on body field /inventory, got %d %+v", code, fieldErrors)
+	}
+}
+
+func TestValidateRequestLetsValidAndUnknownRequestsThrough(t *testing.T) {
+	r := newValidatedRouter(t)
+
+	valid := httptest.NewRequest(http.MethodGet, "/findAvailableProducts?type=gadget", nil)
+	valid.Header.Set("pageSize", "10")
+	if code, fieldErrors := validate(r, valid); code != http.StatusOK {
+		t.Fatalf("expected a valid request to pass, got %d %+v", code, fieldErrors)
+	}
+
+	if code, _ := validate(r, httptest.NewRequest(http.MethodGet, "/health", nil)); code != http.StatusOK {
+		t.Fatalf("expected a route outside the spec to pass, got %d", code)
+	}
+}
+
+// bffSpecPath is the local copy of the provided spec shipped with the BFF.
+const bffSpecPath = "../../../../../../contracts/product_search_bff_v4.yaml"
+
+func TestValidateRequestAgainstShippedSpec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	validator, err := NewRequestValidator(bffSpecPath)
+	if err != nil {
+		t.Fatalf("expected the shipped spec to load, got %v", err)
+	}
+
+	r := gin.New()
+	r.Use(ValidateRequest(validator))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/findAvailableProducts", ok)
+	r.POST("/orders", ok)
+	r.GET("/orders", ok)
+	r.POST("/products", ok)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		pageSize   string
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{"products of a type", http.MethodGet, "/findAvailableProducts?type=book", "10", "", http.StatusOK, ""},
+		{"products of the default type", http.MethodGet, "/findAvailableProducts", "10", "", http.StatusOK, ""},
+		{"unknown product type", http.MethodGet, "/findAvailableProducts?type=toy", "10", "", http.StatusBadRequest, "query:type"},
+		{"missing page size", http.MethodGet, "/findAvailableProducts", "", "", http.StatusBadRequest, "header:pageSize"},
+		{"page size not a number", http.MethodGet, "/findAvailableProducts", "ten", "", http.StatusBadRequest, "header:pageSize"},
+		{"page size zero", http.MethodGet, "/findAvailableProducts", "0", "", http.StatusBadRequest, "header:pageSize"},
+		{"order", http.MethodPost, "/orders", "", `{"productid":1,"count":2}`, http.StatusOK, ""},
+		{"order without count", http.MethodPost, "/orders", "", `{"productid":1}`, http.StatusBadRequest, "body:"},
+		{"order of no item", http.MethodPost, "/orders", "", `{"productid":1,"count":0}`, http.StatusBadRequest, "body:/count"},
+		{"product", http.MethodPost, "/products", "", `{"name":"iPhone","type":"gadget","inventory":10}`, http.StatusOK, ""},
+		{"product over the maximum inventory", http.MethodPost, "/products", "", `{"name":"iPhone","type":"gadget","inventory":102}`, http.StatusBadRequest, "body:/inventory"},
+		{"product of an unknown type", http.MethodPost, "/products", "", `{"name":"iPhone","type":"car","inventory":10}`, http.StatusBadRequest, "body:/type"},
+		{"route outside the spec", http.MethodGet, "/orders?status=shipped", "", "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.pageSize != "" {
+				req.Header.Set("pageSize", tt.pageSize)
+			}
+
+			code, fieldErrors := validate(r, req)
+			if code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d %+v", tt.wantStatus, code, fieldErrors)
+			}
+			if tt.wantError == "" {
+				return
+			}
+			for _, e := range fieldErrors {
+				if strings.HasPrefix(e.In+":"+e.Name, tt.wantError) {
+					return
+				}
+			}
+			t.Fatalf("expected an error on %s, got %+v", tt.wantError, fieldErrors)
+		})
+	}
+}
@@ -3,6 +3,7 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -10,7 +11,7 @@ import (
 	"github.com/znsio/specmatic-order-bff-go/pkg/utils"
 )
 
-func RequirePageSize() gin.HandlerFunc {
+func RequirePageSize(maxPageSize int) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		pageSizeStr := c.GetHeader("pageSize")
 
@@ -36,6 +37,13 @@ func RequirePageSize() gin.HandlerFunc {
 			return
 		}
 
+		// page size should not exceed the configured maximum.
+		if pageSize > maxPageSize {
+			utils.ErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("The provided page size exceeds the maximum of %d.", maxPageSize))
+			c.Abort()
+			return
+		}
+
 		c.Set("pageSize", pageSize)
 		c.Next()
 	}
@@ -0,0 +1,36 @@
+package models
+
+// A checkout has at most 50 line items, as each of them is placed concurrently.
+type CheckoutRequest struct {
+	Items []OrderRequest `json:"items" binding:"required,min=1,max=50,dive"`
+}
+
+type CheckoutStatus string
+
+const (
+	CheckoutStatusCompleted CheckoutStatus = "completed"
+	CheckoutStatusFailed    CheckoutStatus = "failed"
+)
+
+type CheckoutLineStatus string
+
+const (
+	CheckoutLineCreated        CheckoutLineStatus = "created"
+	CheckoutLineFailed         CheckoutLineStatus = "failed"
+	CheckoutLineSkipped        CheckoutLineStatus = "skipped"
+	CheckoutLineRolledBack     CheckoutLineStatus = "rolledback"
+	CheckoutLineRollbackFailed CheckoutLineStatus = "rollbackfailed"
+)
+
+type CheckoutLineResult struct {
+	ProductID int                `json:"productid"`
+	Count     int                `json:"count"`
+	OrderID   int                `json:"orderid,omitempty"`
+	Status    CheckoutLineStatus `json:"status"`
+	Error     string             `json:"error,omitempty"`
+}
+
+type CheckoutResponse struct {
+	Status CheckoutStatus       `json:"status"`
+	Lines  []CheckoutLineResult `json:"lines"`
+}
@@ -12,6 +12,15 @@ type NewProduct struct {
 	Inventory Inventory `json:"inventory" binding:"required,min=1,max=101"`
 }
 
+func (p NewProduct) ToProduct(id int) Product {
+	return Product{
+		ID:        id,
+		Name:      p.Name,
+		Type:      ProductType(p.Type),
+		Inventory: int(p.Inventory),
+	}
+}
+
 /**
 * Custom type for Inventory as it can be provide as a string (int as string) in json or as a string, and both should be
 * accepted.
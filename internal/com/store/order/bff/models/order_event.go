@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+type OrderEventType string
+
+const (
+	OrderEventCreated   OrderEventType = "order-created"
+	OrderEventCancelled OrderEventType = "order-cancelled"
+)
+
+/**
+* OrderEvent is published on the order events topic, keyed by order ID, for every order created or cancelled through
+* the BFF. It carries the order as it is after the change, see asyncapi/order_events.yaml.
+ */
+type OrderEvent struct {
+	Type       OrderEventType `json:"type"`
+	OrderID    int            `json:"orderid"`
+	ProductID  int            `json:"productid"`
+	Count      int            `json:"count"`
+	Status     OrderStatus    `json:"status"`
+	OccurredAt time.Time      `json:"occurredAt"`
+}
+
+func NewOrderEvent(eventType OrderEventType, order Order) OrderEvent {
+	return OrderEvent{
+		Type:       eventType,
+		OrderID:    order.ID,
+		ProductID:  order.ProductID,
+		Count:      order.Count,
+		Status:     order.Status,
+		OccurredAt: time.Now().UTC(),
+	}
+}
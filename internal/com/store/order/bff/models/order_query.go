@@ -0,0 +1,6 @@
+package models
+
+type OrderQuery struct {
+	Status    OrderStatus `form:"status" binding:"omitempty,oneof=pending fulfilled cancelled"`
+	ProductID int         `form:"productid" binding:"omitempty,min=1"`
+}
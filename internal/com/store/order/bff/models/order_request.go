@@ -2,5 +2,5 @@ package models
 
 type OrderRequest struct {
 	ProductID int `json:"productid" binding:"required"`
-	Count     int `json:"count" binding:"required"`
+	Count     int `json:"count" binding:"required,min=1"`
 }
@@ -0,0 +1,26 @@
+package models
+
+type OrderStatus string
+
+const (
+	OrderStatusPending   OrderStatus = "pending"
+	OrderStatusFulfilled OrderStatus = "fulfilled"
+	OrderStatusCancelled OrderStatus = "cancelled"
+)
+
+/**
+* Allowed order status transitions. An order starts as pending and can be moved once, either to fulfilled or to
+* cancelled, both of which are terminal.
+ */
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending: {OrderStatusFulfilled, OrderStatusCancelled},
+}
+
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
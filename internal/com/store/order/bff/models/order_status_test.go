@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestOrderStatusTransitions(t *testing.T) {
+	tests := []struct {
+		from OrderStatus
+		to   OrderStatus
+		want bool
+	}{
+		{OrderStatusPending, OrderStatusFulfilled, true},
+		{OrderStatusPending, OrderStatusCancelled, true},
+		{OrderStatusPending, OrderStatusPending, false},
+		{OrderStatusFulfilled, OrderStatusCancelled, false},
+		{OrderStatusCancelled, OrderStatusFulfilled, false},
+		{OrderStatusCancelled, OrderStatusPending, false},
+		{OrderStatus("unknown"), OrderStatusCancelled, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%q -> %q: got %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package models
+
+// PageRequest identifies which slice of a result set the client asked for. Page is 1-based.
+type PageRequest struct {
+	Page     int
+	PageSize int
+}
+
+type Page[T any] struct {
+	Items    []T
+	Page     int
+	PageSize int
+	Total    int
+}
+
+// Paginate returns the requested page out of the full list of items. Requesting a page beyond the last one returns an
+// empty (non-nil) page so that it is still rendered as [] in json.
+func Paginate[T any](items []T, req PageRequest) Page[T] {
+	page := Page[T]{
+		Items:    []T{},
+		Page:     req.Page,
+		PageSize: req.PageSize,
+		Total:    len(items),
+	}
+
+	start := (req.Page - 1) * req.PageSize
+	if start < 0 || start >= len(items) {
+		return page
+	}
+
+	end := start + req.PageSize
+	if end > len(items) {
+		end = len(items)
+	}
+
+	page.Items = items[start:end]
+	return page
+}
+
+func (p Page[T]) TotalPages() int {
+	if p.PageSize <= 0 {
+		return 0
+	}
+	return (p.Total + p.PageSize - 1) / p.PageSize
+}
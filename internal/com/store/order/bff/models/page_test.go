@@ -0,0 +1,47 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		name          string
+		req           PageRequest
+		wantItems     []int
+		wantTotalPage int
+	}{
+		{"first page", PageRequest{Page: 1, PageSize: 2}, []int{1, 2}, 3},
+		{"middle page", PageRequest{Page: 2, PageSize: 2}, []int{3, 4}, 3},
+		{"last partial page", PageRequest{Page: 3, PageSize: 2}, []int{5}, 3},
+		{"last full page", PageRequest{Page: 1, PageSize: 5}, []int{1, 2, 3, 4, 5}, 1},
+		{"page past the end", PageRequest{Page: 4, PageSize: 2}, []int{}, 3},
+		{"page size over the total", PageRequest{Page: 1, PageSize: 50}, []int{1, 2, 3, 4, 5}, 1},
+		{"page zero", PageRequest{Page: 0, PageSize: 2}, []int{}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := Paginate(items, tt.req)
+			if page.Items == nil {
+				t.Fatal("expected a non-nil page")
+			}
+			if fmt.Sprint(page.Items) != fmt.Sprint(tt.wantItems) {
+				t.Fatalf("expected items %v, got %v", tt.wantItems, page.Items)
+			}
+			if page.Total != len(items) || page.TotalPages() != tt.wantTotalPage {
+				t.Fatalf("expected %d items over %d pages, got %d over %d", len(items), tt.wantTotalPage, page.Total, page.TotalPages())
+			}
+		})
+	}
+}
+
+func TestPaginateEmpty(t *testing.T) {
+	page := Paginate([]int{}, PageRequest{Page: 1, PageSize: 10})
+	if page.Items == nil || len(page.Items) != 0 || page.TotalPages() != 0 {
+		t.Fatalf("expected an empty page without pages, got %+v", page)
+	}
+}
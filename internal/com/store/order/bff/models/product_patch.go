@@ -0,0 +1,23 @@
+package models
+
+/**
+* Partial product update, only the fields present in the incoming json are applied. Inventory reuses the lenient
+* Inventory type so it can be sent either as an int or as a string.
+ */
+type ProductPatch struct {
+	Name      *string    `json:"name" binding:"omitempty,min=1"`
+	Type      *string    `json:"type" binding:"omitempty,oneof=book food gadget other"`
+	Inventory *Inventory `json:"inventory" binding:"omitempty,min=1,max=101"`
+}
+
+func (p ProductPatch) ApplyTo(product *Product) {
+	if p.Name != nil {
+		product.Name = *p.Name
+	}
+	if p.Type != nil {
+		product.Type = ProductType(*p.Type)
+	}
+	if p.Inventory != nil {
+		product.Inventory = int(*p.Inventory)
+	}
+}
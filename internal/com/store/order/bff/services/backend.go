@@ -1,171 +1,400 @@
 package services
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net"
+	"log"
 	"net/http"
-	"time"
+	"net/url"
+	"strconv"
 
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
 	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
 )
 
 type BackendService struct {
-	BaseURL   string
-	AuthToken string
+	credentials CredentialsProvider
+	publisher   Publisher
+	readClient  *http.Client
+	writeClient *http.Client
+	retryPolicy RetryPolicy
+
+	// Clients of the operations with their own timeout, sharing the transport of the read and write clients.
+	operationClients map[string]*http.Client
+
+	endpoints       *endpointPool
+	contract        *contractValidator
+	circuitBreakers *circuitBreakers
+	productCache    *productCache
+	productQueries  productQueryGroup
 }
 
-func NewBackendService(baseURL string, authToken string) *BackendService {
-	return &BackendService{BaseURL: baseURL, AuthToken: authToken}
+// NewBackendService creates the service for the domain API, balancing calls over the given base URLs (one per replica).
+// The products returned by GetAllProducts, and the orders created or cancelled, are published with publisher, unless it
+// is nil.
+func NewBackendService(cfg *config.Config, baseURLs []string, credentials CredentialsProvider, publisher Publisher) (*BackendService, error) {
+	transport, err := newBackendTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	contract, err := newContractValidator(cfg)
+	if err != nil {
+		return nil, err
+	}
+	operationClients, err := newOperationClients(cfg, transport)
+	if err != nil {
+		return nil, err
+	}
+	readClient := &http.Client{Transport: transport, Timeout: cfg.BackendReadTimeout}
+
+	return &BackendService{
+		credentials: credentials,
+		publisher:   publisher,
+		readClient:  readClient,
+		writeClient: &http.Client{Transport: transport, Timeout: cfg.BackendWriteTimeout},
+		retryPolicy: NewRetryPolicy(cfg),
+
+		operationClients: operationClients,
+
+		endpoints:       newEndpointPool(cfg, baseURLs, readClient),
+		contract:        contract,
+		circuitBreakers: newCircuitBreakers(cfg),
+		productCache:    newProductCache(cfg.ProductCacheTTL, cfg.ProductCacheMaxStale),
+	}, nil
+}
+
+// CircuitBreakers reports the state of the circuit breaker of every backend operation called so far.
+func (s *BackendService) CircuitBreakers() []CircuitBreakerStatus {
+	return s.circuitBreakers.statuses()
 }
 
-func (s *BackendService) GetAllProducts(productType string, pageSize int) ([]models.Product, int, error) {
+// Endpoints reports the health of every replica of the domain API.
+func (s *BackendService) Endpoints() []EndpointStatus {
+	return s.endpoints.statuses()
+}
 
-	// Create a new HTTP client with a timeout
-	client := &http.Client{
-		Timeout: 3 * time.Second, // Set the timeout to 3 seconds
+// PublishStats reports on the Kafka messages published for product listings, when the publisher keeps count.
+func (s *BackendService) PublishStats() PublishStats {
+	if reporter, ok := s.publisher.(interface{ Stats() PublishStats }); ok {
+		return reporter.Stats()
 	}
+	return PublishStats{}
+}
 
-	// Construct the URL with query parameters
-	url := fmt.Sprintf("%s/products?type=%s", s.BaseURL, productType)
+// CoalescingStats reports how many product queries were served by sharing an identical in-flight query.
+func (s *BackendService) CoalescingStats() CoalescingStats {
+	return s.productQueries.stats()
+}
 
-	// Make the HTTP GET request
-	resp, err := client.Get(url)
+func (s *BackendService) GetAllProducts(ctx context.Context, productType string, pageRequest models.PageRequest) (models.Page[models.Product], error) {
+	key := fmt.Sprintf("%s|%d|%d", productType, pageRequest.Page, pageRequest.PageSize)
+	return s.productQueries.do(ctx, key, func(ctx context.Context) (models.Page[models.Product], error) {
+		return s.getAllProducts(ctx, productType, pageRequest)
+	})
+}
 
-	// Check for errors, including timeout
+func (s *BackendService) getAllProducts(ctx context.Context, productType string, pageRequest models.PageRequest) (models.Page[models.Product], error) {
+	products, err := s.getProductsOfType(ctx, productType)
 	if err != nil {
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			return nil, http.StatusServiceUnavailable, fmt.Errorf("503 Service Unavailable: %w", err)
+		return models.Page[models.Product]{}, err
+	}
+
+	// The domain API always returns every product of the type, so truncate it to the requested page.
+	page := models.Paginate(products, pageRequest)
+
+	// // Send Kafka messages
+	if s.publisher != nil {
+		if err := s.publisher.PublishProducts(ctx, page.Items); err != nil {
+			return models.Page[models.Product]{}, fmt.Errorf("error sending Kafka messages: %w", err)
 		}
-		return nil, http.StatusServiceUnavailable, fmt.Errorf("503 Service Unavailable: %w", err)
 	}
 
-	defer resp.Body.Close()
+	return page, nil
+}
+
+// getProductsOfType serves the product list from the cache when possible, refreshing stale entries in the background.
+func (s *BackendService) getProductsOfType(ctx context.Context, productType string) ([]models.Product, error) {
+	if !s.productCache.enabled() {
+		return s.fetchProducts(ctx, productType)
+	}
+
+	products, ok, refresh := s.productCache.get(productType)
+	if refresh {
+		go s.refreshProducts(context.WithoutCancel(ctx), productType)
+	}
+	if ok {
+		return products, nil
+	}
+
+	generation := s.productCache.currentGeneration()
+	products, err := s.fetchProducts(ctx, productType)
+	if err != nil {
+		return nil, err
+	}
+
+	s.productCache.put(productType, products, generation)
+	return products, nil
+}
+
+func (s *BackendService) refreshProducts(ctx context.Context, productType string) {
+	generation := s.productCache.currentGeneration()
+	products, err := s.fetchProducts(ctx, productType)
+	if err != nil {
+		log.Printf("Serving stale products of type '%s', refresh failed: %v", productType, err)
+		s.productCache.refreshFailed(productType)
+		return
+	}
+
+	s.productCache.put(productType, products, generation)
+}
+
+func (s *BackendService) fetchProducts(ctx context.Context, productType string) ([]models.Product, error) {
+	// Construct the path with query parameters
+	path := "/products?" + url.Values{"type": {productType}}.Encode()
+
+	// Check for errors, including timeout
+	resp, err := s.do(ctx, "getProducts", http.MethodGet, path, nil)
+	if err != nil {
+		return nil, unavailableError(err)
+	}
 
 	// If the response is not OK, return the error message from the backend
 	if resp.StatusCode != http.StatusOK {
-		return nil, http.StatusInternalServerError, fmt.Errorf("something went wrong, please check if you provided a valid 'type': %w", err)
+		return nil, responseError(resp)
 	}
 
 	var products []models.Product
-	if err := json.NewDecoder(resp.Body).Decode(&products); err != nil {
-		return nil, http.StatusInternalServerError, err
+	if err := json.Unmarshal(resp.Body, &products); err != nil {
+		return nil, fmt.Errorf("error decoding products: %w", err)
 	}
 
-	// // Send Kafka messages
-	err = SendProductMessages(products)
+	return products, nil
+}
+
+func (s *BackendService) CreateProduct(ctx context.Context, newProduct models.NewProduct) (int, error) {
+	resp, err := s.do(ctx, "createProduct", http.MethodPost, "/products", newProduct)
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("error sending Kafka messages: %w", err)
+		return -1, unavailableError(err)
 	}
 
-	return products, -1, nil
-}
+	if resp.StatusCode != http.StatusOK {
+		return -1, responseError(resp)
+	}
 
-func (s *BackendService) CreateProduct(newProduct models.NewProduct) (int, error) {
+	var responseBody map[string]interface{}
+	err = json.Unmarshal(resp.Body, &responseBody)
+	if err != nil {
+		return -1, fmt.Errorf("error unmarshalling response body: %w", err)
+	}
 
-	apiUrl := s.BaseURL + "/products"
+	productID, ok := responseBody["id"].(float64)
+	if !ok {
+		return -1, fmt.Errorf("invalid product id received in response")
+	}
+
+	s.productCache.invalidate(newProduct.Type)
+
+	return int(productID), nil
+}
 
-	requestBody, err := json.Marshal(newProduct)
+func (s *BackendService) CreateOrder(ctx context.Context, orderRequest models.OrderRequest) (int, error) {
+	// Check the product stock first so that the client gets a clear error instead of a failure from the domain API.
+	product, err := s.GetProduct(ctx, orderRequest.ProductID)
 	if err != nil {
 		return -1, err
 	}
 
-	req, err := http.NewRequest("POST", apiUrl, bytes.NewReader(requestBody))
-	if err != nil {
+	if err := checkInventory(product, orderRequest.Count); err != nil {
 		return -1, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authenticate", s.AuthToken)
+	order := models.NewOrder{
+		ProductID: orderRequest.ProductID,
+		Count:     orderRequest.Count,
+		Status:    models.OrderStatusPending,
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.do(ctx, "createOrder", http.MethodPost, "/orders", order)
 	if err != nil {
-		return -1, err
+		return -1, unavailableError(err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return -1, fmt.Errorf("something went wrong, please try again")
+		return -1, responseError(resp)
+	}
+
+	if len(resp.Body) == 0 {
+		return -1, fmt.Errorf("no order id received in Order API response")
 	}
 
 	var responseBody map[string]interface{}
-	bodyBytes, err := io.ReadAll(resp.Body)
+	err = json.Unmarshal(resp.Body, &responseBody)
 	if err != nil {
-		return -1, err
+		return -1, fmt.Errorf("error unmarshalling response body: %w", err)
 	}
-	err = json.Unmarshal(bodyBytes, &responseBody)
+
+	orderID, ok := responseBody["id"].(float64)
+	if !ok {
+		return -1, fmt.Errorf("invalid order id received in response")
+	}
+
+	s.publishOrderEvent(ctx, models.NewOrderEvent(models.OrderEventCreated, models.Order{
+		ID:        int(orderID),
+		ProductID: order.ProductID,
+		Count:     order.Count,
+		Status:    order.Status,
+	}))
+
+	return int(orderID), nil
+}
+
+func (s *BackendService) GetOrder(ctx context.Context, orderID int) (models.Order, error) {
+	resp, err := s.do(ctx, "getOrder", http.MethodGet, fmt.Sprintf("/orders/%d", orderID), nil)
 	if err != nil {
-		return -1, err
+		return models.Order{}, unavailableError(err)
 	}
 
-	productID, ok := responseBody["id"].(float64)
-	if !ok {
-		return -1, fmt.Errorf("something went wrong, please try again")
+	if resp.StatusCode == http.StatusNotFound {
+		return models.Order{}, notFoundError(resp, "order with id %d not found", orderID)
 	}
 
-	return int(productID), nil
+	if resp.StatusCode != http.StatusOK {
+		return models.Order{}, responseError(resp)
+	}
 
+	var order models.Order
+	if err := json.Unmarshal(resp.Body, &order); err != nil {
+		return models.Order{}, fmt.Errorf("error decoding order: %w", err)
+	}
+
+	return order, nil
 }
 
-func (s *BackendService) CreateOrder(orderRequest models.OrderRequest) (int, error) {
-	apiUrl := s.BaseURL + "/orders" // Assuming this is the endpoint for creating orders
+func (s *BackendService) GetOrders(ctx context.Context, query models.OrderQuery, pageRequest models.PageRequest) (models.Page[models.Order], error) {
+	// Filtering is done by the domain API, pagination is done here as the domain API always returns the full list.
+	params := url.Values{}
+	if query.Status != "" {
+		params.Set("status", string(query.Status))
+	}
+	if query.ProductID != 0 {
+		params.Set("productid", strconv.Itoa(query.ProductID))
+	}
 
-	order := models.NewOrder{
-		ProductID: orderRequest.ProductID,
-		Count:     orderRequest.Count,
-		Status:    "pending",
+	path := "/orders"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
 	}
 
-	requestBody, err := json.Marshal(order)
+	resp, err := s.do(ctx, "getOrders", http.MethodGet, path, nil)
 	if err != nil {
-		return -1, fmt.Errorf("error marshalling order: %w", err)
+		return models.Page[models.Order]{}, unavailableError(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return models.Page[models.Order]{}, responseError(resp)
+	}
+
+	var orders []models.Order
+	if err := json.Unmarshal(resp.Body, &orders); err != nil {
+		return models.Page[models.Order]{}, fmt.Errorf("error decoding orders: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", apiUrl, bytes.NewReader(requestBody))
+	return models.Paginate(orders, pageRequest), nil
+}
+
+func (s *BackendService) UpdateOrder(ctx context.Context, order models.Order) error {
+	resp, err := s.do(ctx, "updateOrder", http.MethodPost, fmt.Sprintf("/orders/%d", order.ID), order)
 	if err != nil {
-		return -1, fmt.Errorf("error creating request: %w", err)
+		return unavailableError(err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return notFoundError(resp, "order with id %d not found", order.ID)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authenticate", s.AuthToken)
+	if order.Status == models.OrderStatusCancelled {
+		s.publishOrderEvent(ctx, models.NewOrderEvent(models.OrderEventCancelled, order))
+	}
+
+	return nil
+}
+
+/**
+* publishOrderEvent publishes the event of an order the domain API has already accepted. The change can't be undone
+* anymore, so a failure to publish is only logged rather than failing the request, and the event is still published
+* if the client goes away. Use the outbox for events not to be lost while Kafka is unavailable.
+ */
+func (s *BackendService) publishOrderEvent(ctx context.Context, event models.OrderEvent) {
+	if s.publisher == nil {
+		return
+	}
+	if err := s.publisher.PublishOrderEvent(context.WithoutCancel(ctx), event); err != nil {
+		log.Printf("Failed to publish the %s event of order %d: %v", event.Type, event.OrderID, err)
+	}
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func (s *BackendService) GetProduct(ctx context.Context, productID int) (models.Product, error) {
+	resp, err := s.do(ctx, "getProduct", http.MethodGet, fmt.Sprintf("/products/%d", productID), nil)
 	if err != nil {
-		return -1, fmt.Errorf("error making request: %w", err)
+		return models.Product{}, unavailableError(err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return models.Product{}, notFoundError(resp, "product with id %d not found", productID)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err == nil {
-			fmt.Println("Response body:", string(bodyBytes))
-		}
-		return -1, fmt.Errorf("received non-200 response: %s", resp.Status)
+		return models.Product{}, responseError(resp)
 	}
 
-	var responseBody map[string]interface{}
-	bodyBytes, err := io.ReadAll(resp.Body)
+	var product models.Product
+	if err := json.Unmarshal(resp.Body, &product); err != nil {
+		return models.Product{}, fmt.Errorf("error decoding product: %w", err)
+	}
+
+	return product, nil
+}
+
+func (s *BackendService) UpdateProduct(ctx context.Context, product models.Product) error {
+	resp, err := s.do(ctx, "updateProduct", http.MethodPost, fmt.Sprintf("/products/%d", product.ID), product)
 	if err != nil {
-		return -1, fmt.Errorf("error reading response body: %w", err)
+		return unavailableError(err)
 	}
 
-	if len(bodyBytes) == 0 {
-		return -1, fmt.Errorf("no order id received in Order API response")
+	if resp.StatusCode == http.StatusNotFound {
+		return notFoundError(resp, "product with id %d not found", product.ID)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
 	}
 
-	err = json.Unmarshal(bodyBytes, &responseBody)
+	// The product may have moved from another type, so every cached list may be outdated.
+	s.productCache.invalidateAll()
+
+	return nil
+}
+
+func (s *BackendService) DeleteProduct(ctx context.Context, productID int) error {
+	resp, err := s.do(ctx, "deleteProduct", http.MethodDelete, fmt.Sprintf("/products/%d", productID), nil)
 	if err != nil {
-		return -1, fmt.Errorf("error unmarshalling response body: %w", err)
+		return unavailableError(err)
 	}
 
-	orderID, ok := responseBody["id"].(float64)
-	if !ok {
-		return -1, fmt.Errorf("invalid order id received in response")
+	if resp.StatusCode == http.StatusNotFound {
+		return notFoundError(resp, "product with id %d not found", productID)
 	}
 
-	return int(orderID), nil
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	s.productCache.invalidateAll()
+
+	return nil
 }
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
+)
+
+/**
+* Operations of the domain API used by the handlers. BackendService implements them over http, InMemoryBackend keeps
+* everything in memory for tests and local development.
+ */
+type ProductBackend interface {
+	GetAllProducts(ctx context.Context, productType string, pageRequest models.PageRequest) (models.Page[models.Product], error)
+	GetProduct(ctx context.Context, productID int) (models.Product, error)
+	CreateProduct(ctx context.Context, newProduct models.NewProduct) (int, error)
+	UpdateProduct(ctx context.Context, product models.Product) error
+	DeleteProduct(ctx context.Context, productID int) error
+}
+
+type OrderBackend interface {
+	CreateOrder(ctx context.Context, orderRequest models.OrderRequest) (int, error)
+	GetOrder(ctx context.Context, orderID int) (models.Order, error)
+	GetOrders(ctx context.Context, query models.OrderQuery, pageRequest models.PageRequest) (models.Page[models.Order], error)
+	UpdateOrder(ctx context.Context, order models.Order) error
+}
+
+type Backend interface {
+	ProductBackend
+	OrderBackend
+}
+
+// StatusReporter is implemented by backends that report on their replicas, circuit breakers, query coalescing and
+// Kafka publishing.
+type StatusReporter interface {
+	PublishStats() PublishStats
+	Endpoints() []EndpointStatus
+	CircuitBreakers() []CircuitBreakerStatus
+	CoalescingStats() CoalescingStats
+}
+
+// checkInventory rejects an order for more items than the product has in stock.
+func checkInventory(product models.Product, count int) error {
+	if count > product.Inventory {
+		return &ServiceError{
+			Kind:   ErrConflict,
+			Reason: fmt.Sprintf("insufficient inventory for product %d: requested %d, available %d", product.ID, count, product.Inventory),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+)
+
+/**
+* Transport shared by every call to the domain API so that connections are pooled and kept alive across requests,
+* instead of every call dialling a new connection.
+ */
+func newBackendTransport(cfg *config.Config) (*http.Transport, error) {
+	tlsConfig, err := newBackendTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   cfg.BackendDialTimeout,
+		KeepAlive: cfg.BackendKeepAlive,
+	}
+
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.BackendMaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.BackendMaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.BackendIdleConnTimeout,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}, nil
+}
+
+// backendOperations names every call to the domain API, for the circuit breakers and the per-operation timeouts.
+var backendOperations = []string{
+	"getProducts", "getProduct", "createProduct", "updateProduct", "deleteProduct",
+	"createOrder", "getOrder", "getOrders", "updateOrder",
+}
+
+// newOperationClients creates a client for every operation given its own timeout in DOMAIN_SERVER_OPERATION_TIMEOUTS.
+func newOperationClients(cfg *config.Config, transport http.RoundTripper) (map[string]*http.Client, error) {
+	clients := make(map[string]*http.Client)
+	for operation, timeout := range cfg.BackendOperationTimeouts {
+		if !slices.Contains(backendOperations, operation) {
+			return nil, fmt.Errorf("DOMAIN_SERVER_OPERATION_TIMEOUTS: unknown operation '%s', expected one of %s", operation, strings.Join(backendOperations, ", "))
+		}
+		clients[operation] = &http.Client{Transport: transport, Timeout: timeout}
+	}
+	return clients, nil
+}
+
+// clientFor returns the client of the operation when it has its own timeout, otherwise the read or write client.
+func (s *BackendService) clientFor(operation, method string) *http.Client {
+	if client, exists := s.operationClients[operation]; exists {
+		return client
+	}
+	if method == http.MethodGet {
+		return s.readClient
+	}
+	return s.writeClient
+}
+
+// backendResponse is a fully read response from the domain API.
+type backendResponse struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+}
+
+/**
+* do sends a request to the domain API and reads the whole response. The payload, when not nil, is sent as json. Every
+* attempt times out after the timeout configured for the operation, falling back to the read timeout for GETs and the
+* write timeout for other methods. The request is bound to ctx, so it is aborted as soon as the incoming request is
+* cancelled.
+*
+* Connection failures and retryable status codes are retried according to the retry policy, as long as the request is
+* safe to send again. Every attempt goes through the circuit breaker of the operation, which fails fast while open.
+ */
+func (s *BackendService) do(ctx context.Context, operation, method, path string, payload any) (*backendResponse, error) {
+	var requestBody []byte
+	if payload != nil {
+		var err error
+		requestBody, err = json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling request body: %w", err)
+		}
+	}
+
+	maxAttempts := 1
+	if s.retryPolicy.canRetry(ctx, method) {
+		maxAttempts = s.retryPolicy.MaxAttempts
+	}
+
+	breaker := s.circuitBreakers.get(operation)
+
+	for attempt := 1; ; attempt++ {
+		if err := breaker.Allow(); err != nil {
+			return nil, err
+		}
+
+		// Fetched for every attempt, as retries can outlive a token.
+		credentials, err := s.credentials.Credentials()
+		if err != nil {
+			breaker.Release()
+			return nil, err
+		}
+
+		resp, err := s.send(ctx, s.clientFor(operation, method), method, path, requestBody, credentials)
+
+		// Connection failures and server errors count against the backend, unless the client cancelled the request.
+		switch {
+		case ctx.Err() != nil:
+			breaker.Release()
+		case err != nil:
+			breaker.Record(false)
+		default:
+			breaker.Record(resp.StatusCode < http.StatusInternalServerError)
+		}
+
+		retryable := err != nil && ctx.Err() == nil
+		if err == nil {
+			retryable = s.retryPolicy.RetryableStatusCodes[resp.StatusCode]
+		}
+
+		if !retryable || attempt >= maxAttempts {
+			if attempt > 1 {
+				log.Printf("%s %s finished after %d attempts", method, path, attempt)
+			}
+			if err == nil && s.contract != nil {
+				if err := s.contract.validate(ctx, method, path, resp); err != nil {
+					return nil, err
+				}
+			}
+			return resp, err
+		}
+
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+		}
+		log.Printf("Retrying %s %s (attempt %d of %d failed: %s)", method, path, attempt, maxAttempts, reason)
+
+		if err := s.retryPolicy.wait(ctx, attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *BackendService) send(ctx context.Context, client *http.Client, method, path string, requestBody []byte, credentials http.Header) (*backendResponse, error) {
+	var body io.Reader
+	if requestBody != nil {
+		body = bytes.NewReader(requestBody)
+	}
+
+	endpoint, err := s.endpoints.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.sendTo(ctx, client, endpoint.url, method, path, body, credentials)
+
+	// Only failures of the endpoint itself count against it, not the client going away.
+	s.endpoints.release(endpoint, ctx.Err() != nil || (err == nil && resp.StatusCode < http.StatusInternalServerError))
+
+	return resp, err
+}
+
+func (s *BackendService) sendTo(ctx context.Context, client *http.Client, baseURL, method, path string, body io.Reader, credentials http.Header) (*backendResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, values := range credentials {
+		req.Header[name] = values
+	}
+
+	if idempotencyKey := IdempotencyKeyFrom(ctx); idempotencyKey != "" && method == http.MethodPost {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	return &backendResponse{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header, Body: responseBody}, nil
+}
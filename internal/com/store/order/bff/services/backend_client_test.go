@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+)
+
+func TestBackendAppliesOperationTimeouts(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(r.URL.Path, "/orders") {
+			w.Write([]byte(`{"id":1,"productid":1,"count":1,"status":"pending"}`))
+			return
+		}
+		w.Write([]byte(`{"id":1,"name":"iPhone","type":"gadget","inventory":10}`))
+	}))
+	defer stub.Close()
+
+	backend, err := NewBackendService(&config.Config{
+		BackendProbeInterval:           time.Hour,
+		BackendEjectAfterFailures:      10,
+		BackendRetryMaxAttempts:        1,
+		BackendBreakerFailureThreshold: 10,
+		BackendReadTimeout:             time.Second,
+		BackendWriteTimeout:            time.Second,
+		BackendOperationTimeouts:       map[string]time.Duration{"getOrder": 20 * time.Millisecond},
+	}, []string{stub.URL}, StaticToken("API-TOKEN-SPEC"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := backend.GetOrder(context.Background(), 1); err == nil {
+		t.Fatal("expected getOrder to time out after its own timeout")
+	}
+	if _, err := backend.GetProduct(context.Background(), 1); err != nil {
+		t.Fatalf("expected getProduct to use the read timeout, got %v", err)
+	}
+}
+
+func TestBackendRejectsTimeoutsOfUnknownOperations(t *testing.T) {
+	_, err := NewBackendService(&config.Config{
+		BackendProbeInterval:     time.Hour,
+		BackendOperationTimeouts: map[string]time.Duration{"listOrders": time.Second},
+	}, []string{"http://localhost:1"}, StaticToken("API-TOKEN-SPEC"), nil)
+	if err == nil || !strings.Contains(err.Error(), "listOrders") {
+		t.Fatalf("expected the unknown operation to be reported, got %v", err)
+	}
+}
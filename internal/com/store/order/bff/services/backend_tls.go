@@ -0,0 +1,80 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+)
+
+var ErrCertificatePinMismatch = errors.New("domain API certificate does not match any pinned key")
+
+/**
+* newBackendTLSConfig builds the TLS settings used to reach the domain API over https, or nil when TLS is disabled. On
+* top of the usual chain verification, the connection is refused when pins are configured and none of them matches the
+* public key of a certificate in a verified chain. Certificates the server sent beyond that chain prove nothing, as
+* anyone can append a public certificate to their own.
+ */
+func newBackendTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if !cfg.BackendTLS {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.BackendCAFile != "" {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+
+		pem, err := os.ReadFile(cfg.BackendCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading domain API CA file: %w", err)
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in domain API CA file %s", cfg.BackendCAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if cfg.BackendClientCertFile != "" {
+		clientCert, err := tls.LoadX509KeyPair(cfg.BackendClientCertFile, cfg.BackendClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading domain API client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	if len(cfg.BackendPinnedCertSHA256) > 0 {
+		pins := make(map[string]bool, len(cfg.BackendPinnedCertSHA256))
+		for _, pin := range cfg.BackendPinnedCertSHA256 {
+			pins[pin] = true
+		}
+
+		// Runs after the chain has been verified, so pinning only ever narrows what is trusted.
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			for _, chain := range state.VerifiedChains {
+				for _, cert := range chain {
+					if pins[publicKeyPin(cert)] {
+						return nil
+					}
+				}
+			}
+			return ErrCertificatePinMismatch
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// publicKeyPin is the base64 SHA-256 of the certificate's SubjectPublicKeyInfo, the format used for HTTP public key pins.
+func publicKeyPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
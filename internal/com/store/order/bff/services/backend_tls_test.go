@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+)
+
+// newTLSStub starts a domain API stub over https that only accepts clients presenting clientCert.
+func newTLSStub(t *testing.T, clientCert *x509.Certificate) *httptest.Server {
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	stub := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":1,"name":"iPhone","type":"gadget","inventory":10}`))
+	}))
+	stub.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	stub.StartTLS()
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+// writeClientCert generates a self-signed client certificate and returns it along with its cert and key files.
+func writeClientCert(t *testing.T) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "order-bff"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	dir := t.TempDir()
+	certFile := writePEM(t, filepath.Join(dir, "client.crt"), "CERTIFICATE", der)
+	keyFile := writePEM(t, filepath.Join(dir, "client.key"), "EC PRIVATE KEY", keyDER)
+	return cert, certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) string {
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newTLSTestBackend calls the stub over TLS, trusting the stub's certificate unless cfg already has a CA file.
+func newTLSTestBackend(t *testing.T, stub *httptest.Server, cfg *config.Config) *BackendService {
+	cfg.BackendTLS = true
+	if cfg.BackendCAFile == "" {
+		cfg.BackendCAFile = writePEM(t, filepath.Join(t.TempDir(), "ca.crt"), "CERTIFICATE", stub.Certificate().Raw)
+	}
+	cfg.BackendLoadBalancing = config.LoadBalancingRoundRobin
+	cfg.BackendProbeInterval = time.Hour
+	cfg.BackendRetryMaxAttempts = 1
+	cfg.BackendBreakerFailureThreshold = 100
+	cfg.BackendReadTimeout = 5 * time.Second
+
+	backend, err := NewBackendService(cfg, []string{stub.URL}, StaticToken("API-TOKEN-SPEC"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend
+}
+
+func TestBackendServiceMutualTLS(t *testing.T) {
+	clientCert, certFile, keyFile := writeClientCert(t)
+	stub := newTLSStub(t, clientCert)
+
+	backend := newTLSTestBackend(t, stub, &config.Config{
+		BackendClientCertFile:   certFile,
+		BackendClientKeyFile:    keyFile,
+		BackendPinnedCertSHA256: []string{publicKeyPin(stub.Certificate())},
+	})
+
+	product, err := backend.GetProduct(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("expected the call over mTLS to succeed, got %v", err)
+	}
+	if product.Name != "iPhone" {
+		t.Fatalf("expected iPhone, got %+v", product)
+	}
+}
+
+func TestBackendServiceRejectsUnpinnedCertificate(t *testing.T) {
+	clientCert, certFile, keyFile := writeClientCert(t)
+	stub := newTLSStub(t, clientCert)
+
+	backend := newTLSTestBackend(t, stub, &config.Config{
+		BackendClientCertFile:   certFile,
+		BackendClientKeyFile:    keyFile,
+		BackendPinnedCertSHA256: []string{publicKeyPin(clientCert)},
+	})
+
+	if _, err := backend.GetProduct(context.Background(), 1); !errors.Is(err, ErrCertificatePinMismatch) {
+		t.Fatalf("expected ErrCertificatePinMismatch, got %v", err)
+	}
+}
+
+// newCertificate creates a certificate for 127.0.0.1 signed by parent, or self-signed when parent is nil.
+func newCertificate(t *testing.T, commonName string, isCA bool, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+
+	issuer, signer := template, any(key)
+	if parent != nil {
+		issuer, signer = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestBackendServiceIgnoresPinnedCertificateOutsideVerifiedChain(t *testing.T) {
+	ca := newCertificate(t, "trusted-ca", true, nil)
+	pinned := newCertificate(t, "order-api", false, nil)
+	impostor := newCertificate(t, "impostor", false, &ca)
+
+	// A CA-valid but unpinned certificate, with the public certificate of the pinned server appended.
+	stub := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":1,"name":"iPhone","type":"gadget","inventory":10}`))
+	}))
+	impostor.Certificate = append(impostor.Certificate, pinned.Certificate[0])
+	stub.TLS = &tls.Config{Certificates: []tls.Certificate{impostor}}
+	stub.StartTLS()
+	t.Cleanup(stub.Close)
+
+	caFile := writePEM(t, filepath.Join(t.TempDir(), "ca.crt"), "CERTIFICATE", ca.Certificate[0])
+
+	backend := newTLSTestBackend(t, stub, &config.Config{BackendCAFile: caFile, BackendPinnedCertSHA256: []string{publicKeyPin(pinned.Leaf)}})
+	if _, err := backend.GetProduct(context.Background(), 1); !errors.Is(err, ErrCertificatePinMismatch) {
+		t.Fatalf("expected ErrCertificatePinMismatch, got %v", err)
+	}
+
+	// Pinning the CA of the verified chain is accepted.
+	backend = newTLSTestBackend(t, stub, &config.Config{BackendCAFile: caFile, BackendPinnedCertSHA256: []string{publicKeyPin(ca.Leaf)}})
+	if _, err := backend.GetProduct(context.Background(), 1); err != nil {
+		t.Fatalf("expected a pinned CA to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
+)
+
+/**
+* Checkout places one order per line item concurrently. If any line fails, the orders already created for the other
+* lines are cancelled so that the checkout is all or nothing. Along with the per-line results it returns the error of
+* the first failed line when the checkout did not complete.
+ */
+func Checkout(ctx context.Context, backend Backend, items []models.OrderRequest) (models.CheckoutResponse, error) {
+	lines := make([]models.CheckoutLineResult, len(items))
+	errs := make([]error, len(items))
+
+	// Lines are placed concurrently, so the stock of a product ordered on several lines is checked for all of them
+	// before any order is created.
+	if failed := checkCombinedInventory(ctx, backend, items); len(failed) > 0 {
+		var firstErr error
+		for i, item := range items {
+			lines[i] = models.CheckoutLineResult{ProductID: item.ProductID, Count: item.Count, Status: models.CheckoutLineSkipped}
+			if err, ok := failed[item.ProductID]; ok {
+				lines[i].Status = models.CheckoutLineFailed
+				lines[i].Error = err.Error()
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+		return models.CheckoutResponse{Status: models.CheckoutStatusFailed, Lines: lines}, firstErr
+	}
+
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func(i int, item models.OrderRequest) {
+			defer wg.Done()
+
+			lines[i] = models.CheckoutLineResult{ProductID: item.ProductID, Count: item.Count}
+
+			orderID, err := backend.CreateOrder(deriveIdempotencyKey(ctx, "line-%d", i), item)
+			if err != nil {
+				lines[i].Status = models.CheckoutLineFailed
+				lines[i].Error = err.Error()
+				errs[i] = err
+				return
+			}
+
+			lines[i].OrderID = orderID
+			lines[i].Status = models.CheckoutLineCreated
+		}(i, item)
+	}
+	wg.Wait()
+
+	var firstErr error
+	for _, err := range errs {
+		if err != nil {
+			firstErr = err
+			break
+		}
+	}
+
+	if firstErr == nil {
+		return models.CheckoutResponse{Status: models.CheckoutStatusCompleted, Lines: lines}, nil
+	}
+
+	// Roll back even if the client has gone away in the meantime, otherwise the created orders would be left behind.
+	rollbackCheckout(context.WithoutCancel(ctx), backend, lines)
+	return models.CheckoutResponse{Status: models.CheckoutStatusFailed, Lines: lines}, firstErr
+}
+
+// checkCombinedInventory returns the error of each product ordered on several lines whose total count is not in stock.
+func checkCombinedInventory(ctx context.Context, products ProductBackend, items []models.OrderRequest) map[int]error {
+	lineCounts := make(map[int]int)
+	totalCounts := make(map[int]int)
+	for _, item := range items {
+		lineCounts[item.ProductID]++
+		totalCounts[item.ProductID] += item.Count
+	}
+
+	failed := make(map[int]error)
+	for productID, total := range totalCounts {
+		if lineCounts[productID] < 2 {
+			continue
+		}
+
+		product, err := products.GetProduct(ctx, productID)
+		if err == nil {
+			err = checkInventory(product, total)
+		}
+		if err != nil {
+			failed[productID] = err
+		}
+	}
+	return failed
+}
+
+func rollbackCheckout(ctx context.Context, orders OrderBackend, lines []models.CheckoutLineResult) {
+	for i, line := range lines {
+		if line.Status != models.CheckoutLineCreated {
+			continue
+		}
+
+		order := models.Order{
+			ID:        line.OrderID,
+			ProductID: line.ProductID,
+			Count:     line.Count,
+			Status:    models.OrderStatusCancelled,
+		}
+
+		if err := orders.UpdateOrder(deriveIdempotencyKey(ctx, "rollback-%d", line.OrderID), order); err != nil {
+			log.Printf("Error cancelling order (ID: %d) during checkout rollback: %v", line.OrderID, err)
+			lines[i].Status = models.CheckoutLineRollbackFailed
+			lines[i].Error = err.Error()
+			continue
+		}
+
+		lines[i].Status = models.CheckoutLineRolledBack
+	}
+}
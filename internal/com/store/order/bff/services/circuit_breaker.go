@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+type CircuitBreakerStatus struct {
+	Operation           string       `json:"operation"`
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutiveFailures"`
+	OpenedAt            *time.Time   `json:"openedAt,omitempty"`
+}
+
+/**
+* CircuitBreaker guards a single backend operation. After FailureThreshold consecutive failures it opens and rejects
+* calls straight away. Once OpenTimeout has passed it lets up to HalfOpenMaxCalls trial calls through (half-open):
+* if they all succeed it closes again, a single failure opens it again.
+ */
+type CircuitBreaker struct {
+	operation        string
+	failureThreshold int
+	openTimeout      time.Duration
+	halfOpenMaxCalls int
+
+	mu                  sync.Mutex
+	state               CircuitState
+	consecutiveFailures int
+	openedAt            time.Time
+	halfOpenCalls       int
+	halfOpenSuccesses   int
+}
+
+func NewCircuitBreaker(operation string, failureThreshold int, openTimeout time.Duration, halfOpenMaxCalls int) *CircuitBreaker {
+	return &CircuitBreaker{
+		operation:        operation,
+		failureThreshold: max(failureThreshold, 1),
+		openTimeout:      openTimeout,
+		halfOpenMaxCalls: max(halfOpenMaxCalls, 1),
+		state:            CircuitClosed,
+	}
+}
+
+// Allow returns ErrCircuitOpen when the call must not be made. Every allowed call must be followed by Record or Release.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen {
+		if time.Since(b.openedAt) < b.openTimeout {
+			return fmt.Errorf("%w for %s", ErrCircuitOpen, b.operation)
+		}
+		b.setState(CircuitHalfOpen)
+	}
+
+	if b.state == CircuitHalfOpen {
+		if b.halfOpenCalls >= b.halfOpenMaxCalls {
+			return fmt.Errorf("%w for %s", ErrCircuitOpen, b.operation)
+		}
+		b.halfOpenCalls++
+	}
+
+	return nil
+}
+
+func (b *CircuitBreaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.consecutiveFailures = 0
+		if b.state == CircuitHalfOpen {
+			b.halfOpenSuccesses++
+			if b.halfOpenSuccesses >= b.halfOpenMaxCalls {
+				b.setState(CircuitClosed)
+			}
+		}
+		return
+	}
+
+	b.consecutiveFailures++
+	if b.state == CircuitHalfOpen || b.consecutiveFailures >= b.failureThreshold {
+		b.setState(CircuitOpen)
+	}
+}
+
+// Release gives back an allowed call whose outcome says nothing about the backend, e.g. when the client went away.
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitHalfOpen && b.halfOpenCalls > 0 {
+		b.halfOpenCalls--
+	}
+}
+
+func (b *CircuitBreaker) Status() CircuitBreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := CircuitBreakerStatus{
+		Operation:           b.operation,
+		State:               b.state,
+		ConsecutiveFailures: b.consecutiveFailures,
+	}
+	if b.state != CircuitClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// setState must be called with the lock held.
+func (b *CircuitBreaker) setState(state CircuitState) {
+	if b.state == state {
+		return
+	}
+
+	log.Printf("Circuit breaker for %s changed from %s to %s", b.operation, b.state, state)
+	b.state = state
+	b.halfOpenCalls = 0
+	b.halfOpenSuccesses = 0
+	if state == CircuitOpen {
+		b.openedAt = time.Now()
+	}
+}
+
+// circuitBreakers lazily creates one breaker per backend operation, all sharing the same configuration.
+type circuitBreakers struct {
+	cfg *config.Config
+
+	mu       sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+func newCircuitBreakers(cfg *config.Config) *circuitBreakers {
+	return &circuitBreakers{cfg: cfg, breakers: make(map[string]*CircuitBreaker)}
+}
+
+func (c *circuitBreakers) get(operation string) *CircuitBreaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	breaker, exists := c.breakers[operation]
+	if !exists {
+		breaker = NewCircuitBreaker(operation, c.cfg.BackendBreakerFailureThreshold, c.cfg.BackendBreakerOpenTimeout, c.cfg.BackendBreakerHalfOpenMaxCalls)
+		c.breakers[operation] = breaker
+	}
+	return breaker
+}
+
+func (c *circuitBreakers) statuses() []CircuitBreakerStatus {
+	c.mu.Lock()
+	breakers := make([]*CircuitBreaker, 0, len(c.breakers))
+	for _, breaker := range c.breakers {
+		breakers = append(breakers, breaker)
+	}
+	c.mu.Unlock()
+
+	statuses := make([]CircuitBreakerStatus, 0, len(breakers))
+	for _, breaker := range breakers {
+		statuses = append(statuses, breaker.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Operation < statuses[j].Operation })
+	return statuses
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	breaker := NewCircuitBreaker("getProducts", 2, time.Hour, 1)
+
+	for i := 0; i < 2; i++ {
+		if err := breaker.Allow(); err != nil {
+			t.Fatalf("call %d: expected breaker to allow, got %v", i, err)
+		}
+		breaker.Record(false)
+	}
+
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if state := breaker.Status().State; state != CircuitOpen {
+		t.Fatalf("expected state %s, got %s", CircuitOpen, state)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	breaker := NewCircuitBreaker("getProducts", 1, time.Millisecond, 1)
+	_ = breaker.Allow()
+	breaker.Record(false)
+
+	time.Sleep(5 * time.Millisecond)
+
+	// Only one trial call is let through while half-open.
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("expected trial call to be allowed, got %v", err)
+	}
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected second call to be rejected while half-open, got %v", err)
+	}
+
+	breaker.Record(true)
+	if state := breaker.Status().State; state != CircuitClosed {
+		t.Fatalf("expected state %s after successful trial, got %s", CircuitClosed, state)
+	}
+}
+
+func TestCircuitBreakerReopensOnFailedTrial(t *testing.T) {
+	breaker := NewCircuitBreaker("getProducts", 1, time.Millisecond, 1)
+	_ = breaker.Allow()
+	breaker.Record(false)
+
+	time.Sleep(5 * time.Millisecond)
+
+	_ = breaker.Allow()
+	breaker.Record(false)
+	if state := breaker.Status().State; state != CircuitOpen {
+		t.Fatalf("expected state %s after failed trial, got %s", CircuitOpen, state)
+	}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
+)
+
+type CoalescingStats struct {
+	Calls          int64 `json:"calls"`
+	UpstreamCalls  int64 `json:"upstreamCalls"`
+	CoalescedCalls int64 `json:"coalescedCalls"`
+}
+
+// productQuery is a product query in flight, shared by its waiters.
+type productQuery struct {
+	done    chan struct{}
+	page    models.Page[models.Product]
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+/**
+* productQueryGroup collapses concurrent identical product queries into a single call: the first caller runs it and
+* every caller arriving while it is in flight waits for and shares its result, Kafka publish included.
+*
+* The shared call keeps the deadline of the caller that started it, but not its cancellation: it runs for as long as
+* any caller is still waiting, and is cancelled as soon as the last one goes away.
+ */
+type productQueryGroup struct {
+	mu            sync.Mutex
+	inFlight      map[string]*productQuery
+	calls         atomic.Int64
+	upstreamCalls atomic.Int64
+}
+
+func (g *productQueryGroup) do(ctx context.Context, key string, query func(ctx context.Context) (models.Page[models.Product], error)) (models.Page[models.Product], error) {
+	g.calls.Add(1)
+
+	g.mu.Lock()
+	if g.inFlight == nil {
+		g.inFlight = make(map[string]*productQuery)
+	}
+	call, exists := g.inFlight[key]
+	if !exists {
+		call = g.start(ctx, key, query)
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.page, call.err
+	case <-ctx.Done():
+		g.leave(key, call)
+		return models.Page[models.Product]{}, unavailableError(ctx.Err())
+	}
+}
+
+// start must be called with the lock held.
+func (g *productQueryGroup) start(ctx context.Context, key string, query func(ctx context.Context) (models.Page[models.Product], error)) *productQuery {
+	g.upstreamCalls.Add(1)
+
+	var sharedCtx context.Context
+	var cancel context.CancelFunc
+	if deadline, ok := ctx.Deadline(); ok {
+		sharedCtx, cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+	} else {
+		sharedCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+	}
+
+	call := &productQuery{done: make(chan struct{}), cancel: cancel}
+	g.inFlight[key] = call
+
+	go func() {
+		defer cancel()
+
+		call.page, call.err = query(sharedCtx)
+
+		g.mu.Lock()
+		if g.inFlight[key] == call {
+			delete(g.inFlight, key)
+		}
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	return call
+}
+
+// leave cancels the shared call once its last waiter has gone, so that callers arriving later start a new one.
+func (g *productQueryGroup) leave(key string, call *productQuery) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	call.waiters--
+	if call.waiters > 0 {
+		return
+	}
+	call.cancel()
+	if g.inFlight[key] == call {
+		delete(g.inFlight, key)
+	}
+}
+
+func (g *productQueryGroup) stats() CoalescingStats {
+	calls := g.calls.Load()
+	upstreamCalls := g.upstreamCalls.Load()
+	return CoalescingStats{
+		Calls:          calls,
+		UpstreamCalls:  upstreamCalls,
+		CoalescedCalls: calls - upstreamCalls,
+	}
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
+)
+
+// waitForCalls waits until the group has seen that many calls, so that every caller is waiting on the shared one.
+func waitForCalls(t *testing.T, g *productQueryGroup, calls int64) {
+	deadline := time.Now().Add(time.Second)
+	for g.stats().Calls < calls {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d calls, got %+v", calls, g.stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestProductQueryGroupSharesInFlightCall(t *testing.T) {
+	var g productQueryGroup
+	release := make(chan struct{})
+	query := func(context.Context) (models.Page[models.Product], error) {
+		<-release
+		return models.Page[models.Product]{Items: cachedGadgets, Total: 1}, nil
+	}
+
+	var wg sync.WaitGroup
+	pages := make([]models.Page[models.Product], 5)
+	for i := range pages {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			page, err := g.do(context.Background(), "gadget|1|10", query)
+			if err != nil {
+				t.Error(err)
+			}
+			pages[i] = page
+		}(i)
+	}
+	waitForCalls(t, &g, 5)
+	close(release)
+	wg.Wait()
+
+	for i, page := range pages {
+		if page.Total != 1 {
+			t.Fatalf("expected caller %d to get the shared result, got %+v", i, page)
+		}
+	}
+	if stats := g.stats(); stats != (CoalescingStats{Calls: 5, UpstreamCalls: 1, CoalescedCalls: 4}) {
+		t.Fatalf("unexpected coalescing stats %+v", stats)
+	}
+}
+
+func TestProductQueryGroupKeepsSharedCallWhenWaiterCancels(t *testing.T) {
+	var g productQueryGroup
+	release := make(chan struct{})
+	sharedCtxErr := make(chan error, 1)
+	query := func(ctx context.Context) (models.Page[models.Product], error) {
+		<-release
+		sharedCtxErr <- ctx.Err()
+		return models.Page[models.Product]{Total: 1}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		_, err := g.do(ctx, "gadget|1|10", query)
+		cancelled <- err
+	}()
+	waitForCalls(t, &g, 1)
+
+	other := make(chan models.Page[models.Product], 1)
+	go func() {
+		page, _ := g.do(context.Background(), "gadget|1|10", query)
+		other <- page
+	}()
+	waitForCalls(t, &g, 2)
+
+	cancel()
+	if err := <-cancelled; err == nil {
+		t.Fatal("expected the cancelled caller to give up")
+	}
+	close(release)
+
+	if page := <-other; page.Total != 1 {
+		t.Fatalf("expected the other caller to get the shared result, got %+v", page)
+	}
+	if err := <-sharedCtxErr; err != nil {
+		t.Fatalf("expected the shared call not to be cancelled, got %v", err)
+	}
+}
+
+func TestProductQueryGroupCancelsSharedCallWhenLastWaiterLeaves(t *testing.T) {
+	var g productQueryGroup
+	sharedCtxErr := make(chan error, 1)
+	query := func(ctx context.Context) (models.Page[models.Product], error) {
+		<-ctx.Done()
+		sharedCtxErr <- ctx.Err()
+		return models.Page[models.Product]{}, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := g.do(ctx, "gadget|1|10", query); err == nil {
+				t.Error("expected the cancelled caller to give up")
+			}
+		}()
+	}
+	waitForCalls(t, &g, 3)
+
+	cancel()
+	wg.Wait()
+
+	select {
+	case err := <-sharedCtxErr:
+		if err != context.Canceled {
+			t.Fatalf("expected the shared call to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the shared call to be cancelled once every caller had gone")
+	}
+
+	// A caller arriving afterwards starts a new call instead of joining the cancelled one.
+	page, err := g.do(context.Background(), "gadget|1|10", func(context.Context) (models.Page[models.Product], error) {
+		return models.Page[models.Product]{Total: 1}, nil
+	})
+	if err != nil || page.Total != 1 {
+		t.Fatalf("expected a new call to succeed, got %+v %v", page, err)
+	}
+	if stats := g.stats(); stats.UpstreamCalls != 2 {
+		t.Fatalf("expected a second upstream call, got %+v", stats)
+	}
+}
+
+func TestProductQueryGroupKeepsDeadlineOfFirstCaller(t *testing.T) {
+	var g productQueryGroup
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	want, _ := ctx.Deadline()
+
+	var got time.Time
+	var hasDeadline bool
+	g.do(ctx, "gadget|1|10", func(ctx context.Context) (models.Page[models.Product], error) {
+		got, hasDeadline = ctx.Deadline()
+		return models.Page[models.Product]{}, nil
+	})
+
+	if !hasDeadline || !got.Equal(want) {
+		t.Fatalf("expected the shared call to keep the deadline %v, got %v", want, got)
+	}
+}
+
+func TestBackendCoalescesProductQueriesAndPublishesOnce(t *testing.T) {
+	release := make(chan struct{})
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"id":1,"name":"iPhone","type":"gadget","inventory":10}]`))
+	}))
+	defer stub.Close()
+
+	publisher := &recordingPublisher{}
+	backend, err := NewBackendService(&config.Config{
+		BackendProbeInterval:    time.Hour,
+		BackendRetryMaxAttempts: 1,
+		BackendReadTimeout:      5 * time.Second,
+	}, []string{stub.URL}, StaticToken("API-TOKEN-SPEC"), publisher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := backend.GetAllProducts(context.Background(), "gadget", models.PageRequest{Page: 1, PageSize: 10}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	waitForCalls(t, &backend.productQueries, 5)
+	close(release)
+	wg.Wait()
+
+	if stats := backend.CoalescingStats(); stats.UpstreamCalls != 1 || stats.CoalescedCalls != 4 {
+		t.Fatalf("expected a single upstream call, got %+v", stats)
+	}
+	if publisher.productBatches != 1 {
+		t.Fatalf("expected the products to be published once, got %d", publisher.productBatches)
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+)
+
+var ErrContractViolation = errors.New("domain API response violates its contract")
+
+/**
+* contractValidator checks the responses of the domain API against the OpenAPI contract the BFF consumes, to catch
+* drift between the two before it reaches production. Violations are logged, and in reject mode the response is
+* discarded as if the domain API had failed.
+ */
+type contractValidator struct {
+	router routers.Router
+	reject bool
+}
+
+// newContractValidator loads the contract, or returns nil when response validation is off.
+func newContractValidator(cfg *config.Config) (*contractValidator, error) {
+	if cfg.BackendResponseValidation != config.ResponseValidationLog && cfg.BackendResponseValidation != config.ResponseValidationReject {
+		return nil, nil
+	}
+
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+
+	doc, err := loader.LoadFromFile(cfg.BackendContractPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading domain API contract %s: %w", cfg.BackendContractPath, err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("invalid domain API contract %s: %w", cfg.BackendContractPath, err)
+	}
+
+	// Responses are matched on their path only, whichever replica of the domain API they come from.
+	doc.Servers = nil
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("error routing domain API contract %s: %w", cfg.BackendContractPath, err)
+	}
+
+	return &contractValidator{
+		router: router,
+		reject: cfg.BackendResponseValidation == config.ResponseValidationReject,
+	}, nil
+}
+
+func (v *contractValidator) validate(ctx context.Context, method, path string, resp *backendResponse) error {
+	err := v.check(ctx, method, path, resp)
+	if err == nil {
+		return nil
+	}
+
+	violation := describeViolation(err)
+	log.Printf("Contract violation in response to %s %s: %s", method, path, violation)
+	if !v.reject {
+		return nil
+	}
+	return fmt.Errorf("%w: %s %s: %s", ErrContractViolation, method, path, violation)
+}
+
+// describeViolation keeps schema errors to a single line, without the schema and value kin-openapi adds to them.
+func describeViolation(err error) string {
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		return fmt.Sprintf("body doesn't match the schema at /%s: %s", strings.Join(schemaErr.JSONPointer(), "/"), schemaErr.Reason)
+	}
+	return err.Error()
+}
+
+func (v *contractValidator) check(ctx context.Context, method, path string, resp *backendResponse) error {
+	req, err := http.NewRequestWithContext(ctx, method, path, nil)
+	if err != nil {
+		return err
+	}
+
+	route, pathParams, err := v.router.FindRoute(req)
+	if err != nil {
+		return fmt.Errorf("operation not in the contract: %w", err)
+	}
+
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: pathParams,
+			Route:      route,
+		},
+		Status:  resp.StatusCode,
+		Header:  resp.Header,
+		Options: &openapi3filter.Options{IncludeResponseStatus: true},
+	}
+	input.SetBodyBytes(resp.Body)
+
+	return openapi3filter.ValidateResponse(ctx, input)
+}
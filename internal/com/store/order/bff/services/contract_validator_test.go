@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
+)
+
+const testOrderContract = `
+openapi: 3.0.0
+info:
+  title: Order API
+  version: "3"
+servers:
+  - url: http://localhost:9000
+paths:
+  /products/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: integer
+      responses:
+        "200":
+          description: The product
+          content:
+            application/json:
+              schema:
+                type: object
+                required: [id, name, type, inventory]
+                properties:
+                  id:
+                    type: integer
+                  name:
+                    type: string
+                  type:
+                    type: string
+                  inventory:
+                    type: integer
+`
+
+func newContractTestBackend(t *testing.T, validation, body string) *BackendService {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(stub.Close)
+
+	contractPath := filepath.Join(t.TempDir(), "api_order_v3.yaml")
+	if err := os.WriteFile(contractPath, []byte(testOrderContract), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	backend, err := NewBackendService(&config.Config{
+		BackendResponseValidation: validation,
+		BackendContractPath:       contractPath,
+		BackendProbeInterval:      time.Hour,
+		BackendRetryMaxAttempts:   1,
+		BackendReadTimeout:        time.Second,
+	}, []string{stub.URL}, StaticToken("API-TOKEN-SPEC"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend
+}
+
+func TestContractValidationRejectsViolations(t *testing.T) {
+	backend := newContractTestBackend(t, config.ResponseValidationReject, `{"id":1,"name":"iPhone","type":"gadget"}`)
+
+	if _, err := backend.GetProduct(context.Background(), 1); !errors.Is(err, ErrContractViolation) {
+		t.Fatalf("expected ErrContractViolation, got %v", err)
+	}
+}
+
+func TestContractValidationLogsViolations(t *testing.T) {
+	backend := newContractTestBackend(t, config.ResponseValidationLog, `{"id":1,"name":"iPhone","type":"gadget"}`)
+
+	product, err := backend.GetProduct(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("expected the response to be accepted in log mode, got %v", err)
+	}
+	if product.Name != "iPhone" {
+		t.Fatalf("expected iPhone, got %+v", product)
+	}
+}
+
+func TestContractValidationAcceptsConformingResponse(t *testing.T) {
+	backend := newContractTestBackend(t, config.ResponseValidationReject, `{"id":1,"name":"iPhone","type":"gadget","inventory":10}`)
+
+	if _, err := backend.GetProduct(context.Background(), 1); err != nil {
+		t.Fatalf("expected a conforming response to be accepted, got %v", err)
+	}
+}
+
+// orderContractPath is the local copy of the consumed contract shipped with the BFF.
+const orderContractPath = "../../../../../../contracts/api_order_v3.yaml"
+
+// domainResponses answers every call of the BFF to the domain API, keyed by method and path.
+var domainResponses = map[string]struct {
+	contentType string
+	body        string
+}{
+	"GET /products":      {"application/json", `[{"id":1,"name":"iPhone","type":"gadget","inventory":302}]`},
+	"POST /products":     {"application/json", `{"id":2}`},
+	"GET /products/1":    {"application/json", `{"id":1,"name":"iPhone","type":"gadget","inventory":10}`},
+	"POST /products/1":   {"text/plain", "success"},
+	"DELETE /products/1": {"text/plain", "success"},
+	"GET /orders":        {"application/json", `[{"id":1,"productid":1,"count":1,"status":"pending"}]`},
+	"POST /orders":       {"application/json", `{"id":1}`},
+	"GET /orders/1":      {"application/json", `{"id":1,"productid":1,"count":1,"status":"pending"}`},
+	"POST /orders/1":     {"text/plain", "success"},
+}
+
+func TestOrderContractDescribesEveryBackendCall(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, exists := domainResponses[r.Method+" "+r.URL.Path]
+		if !exists {
+			t.Errorf("unexpected call %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", response.contentType)
+		w.Write([]byte(response.body))
+	}))
+	defer stub.Close()
+
+	backend, err := NewBackendService(&config.Config{
+		BackendResponseValidation: config.ResponseValidationReject,
+		BackendContractPath:       orderContractPath,
+		BackendProbeInterval:      time.Hour,
+		BackendRetryMaxAttempts:   1,
+		BackendReadTimeout:        time.Second,
+		BackendWriteTimeout:       time.Second,
+	}, []string{stub.URL}, StaticToken("API-TOKEN-SPEC"), nil)
+	if err != nil {
+		t.Fatalf("expected the shipped contract to load, got %v", err)
+	}
+
+	ctx := context.Background()
+	product := models.Product{ID: 1, Name: "iPhone", Type: models.TypeGadget, Inventory: 10}
+	order := models.Order{ID: 1, ProductID: 1, Count: 1, Status: models.OrderStatusCancelled}
+	calls := map[string]func() error{
+		"getProducts": func() error {
+			_, err := backend.GetAllProducts(ctx, "gadget", models.PageRequest{Page: 1, PageSize: 10})
+			return err
+		},
+		"createProduct": func() error {
+			_, err := backend.CreateProduct(ctx, models.NewProduct{Name: "iPhone", Type: "gadget", Inventory: 10})
+			return err
+		},
+		"getProduct":    func() error { _, err := backend.GetProduct(ctx, 1); return err },
+		"updateProduct": func() error { return backend.UpdateProduct(ctx, product) },
+		"deleteProduct": func() error { return backend.DeleteProduct(ctx, 1) },
+		"createOrder": func() error {
+			_, err := backend.CreateOrder(ctx, models.OrderRequest{ProductID: 1, Count: 1})
+			return err
+		},
+		"getOrder": func() error { _, err := backend.GetOrder(ctx, 1); return err },
+		"getOrders": func() error {
+			_, err := backend.GetOrders(ctx, models.OrderQuery{Status: models.OrderStatusPending}, models.PageRequest{Page: 1, PageSize: 10})
+			return err
+		},
+		"updateOrder": func() error { return backend.UpdateOrder(ctx, order) },
+	}
+
+	for _, operation := range backendOperations {
+		t.Run(operation, func(t *testing.T) {
+			call, exists := calls[operation]
+			if !exists {
+				t.Fatalf("no call of operation %s in the test", operation)
+			}
+			if err := call(); err != nil {
+				t.Fatalf("expected a conforming response to be accepted, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOrderContractRejectsInvalidProductList(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"id":1,"name":"iPhone","type":"toy","inventory":10}]`))
+	}))
+	defer stub.Close()
+
+	backend, err := NewBackendService(&config.Config{
+		BackendResponseValidation: config.ResponseValidationReject,
+		BackendContractPath:       orderContractPath,
+		BackendProbeInterval:      time.Hour,
+		BackendRetryMaxAttempts:   1,
+		BackendReadTimeout:        time.Second,
+	}, []string{stub.URL}, StaticToken("API-TOKEN-SPEC"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := backend.GetAllProducts(context.Background(), "gadget", models.PageRequest{Page: 1, PageSize: 10}); !errors.Is(err, ErrContractViolation) {
+		t.Fatalf("expected ErrContractViolation, got %v", err)
+	}
+}
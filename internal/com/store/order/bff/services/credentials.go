@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// CredentialsProvider supplies the headers authenticating the BFF with the domain API, sent on every request.
+type CredentialsProvider interface {
+	Credentials() (http.Header, error)
+}
+
+// NewCredentialsProvider creates the provider selected by DOMAIN_SERVER_CREDENTIALS.
+func NewCredentialsProvider(cfg *config.Config) (CredentialsProvider, error) {
+	switch cfg.BackendCredentials {
+	case config.CredentialsOAuth2:
+		return NewOAuth2Credentials(cfg), nil
+	default:
+		if cfg.BackendAuthTokenFile == "" {
+			return StaticToken(cfg.BackendAuthToken), nil
+		}
+
+		token, err := os.ReadFile(cfg.BackendAuthTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading domain API token file: %w", err)
+		}
+		return StaticToken(strings.TrimSpace(string(token))), nil
+	}
+}
+
+// StaticToken is a fixed API token, sent in the Authenticate header expected by the domain API.
+type StaticToken string
+
+func (t StaticToken) Credentials() (http.Header, error) {
+	return http.Header{"Authenticate": {string(t)}}, nil
+}
+
+/**
+* OAuth2Credentials gets bearer tokens from an authorization server with the client credentials grant. A token is
+* cached and shared by all requests until refreshBefore ahead of its expiry, when the next request fetches a new one,
+* so that no request is sent with a token about to expire on the way.
+ */
+type OAuth2Credentials struct {
+	tokens oauth2.TokenSource
+}
+
+func NewOAuth2Credentials(cfg *config.Config) *OAuth2Credentials {
+	clientConfig := &clientcredentials.Config{
+		ClientID:     cfg.BackendOAuth2ClientID,
+		ClientSecret: cfg.BackendOAuth2ClientSecret,
+		TokenURL:     cfg.BackendOAuth2TokenURL,
+		Scopes:       cfg.BackendOAuth2Scopes,
+	}
+
+	// Token requests are not tied to any incoming request, as the token is shared by all of them.
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Timeout: cfg.BackendWriteTimeout})
+	fetch := tokenSourceFunc(func() (*oauth2.Token, error) {
+		return clientConfig.Token(ctx)
+	})
+
+	return &OAuth2Credentials{tokens: oauth2.ReuseTokenSourceWithExpiry(nil, fetch, cfg.BackendOAuth2RefreshBefore)}
+}
+
+func (c *OAuth2Credentials) Credentials() (http.Header, error) {
+	token, err := c.tokens.Token()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching domain API access token: %w", err)
+	}
+	return http.Header{"Authorization": {token.Type() + " " + token.AccessToken}}, nil
+}
+
+// tokenSourceFunc fetches a new token on every call, caching is left to oauth2.ReuseTokenSourceWithExpiry.
+type tokenSourceFunc func() (*oauth2.Token, error)
+
+func (f tokenSourceFunc) Token() (*oauth2.Token, error) {
+	return f()
+}
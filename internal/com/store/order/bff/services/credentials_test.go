@@ -0,0 +1,66 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+)
+
+func newTokenServer(t *testing.T, expiresIn int) (*httptest.Server, *atomic.Int32) {
+	var issued atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "client_credentials" {
+			t.Errorf("expected the client_credentials grant, got %q", r.FormValue("grant_type"))
+		}
+		n := issued.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":%d}`, n, expiresIn)
+	}))
+	t.Cleanup(server.Close)
+	return server, &issued
+}
+
+func newTestOAuth2Credentials(tokenURL string) *OAuth2Credentials {
+	return NewOAuth2Credentials(&config.Config{
+		BackendOAuth2TokenURL:      tokenURL,
+		BackendOAuth2ClientID:      "order-bff",
+		BackendOAuth2ClientSecret:  "secret",
+		BackendOAuth2RefreshBefore: time.Minute,
+		BackendWriteTimeout:        time.Second,
+	})
+}
+
+func TestOAuth2CredentialsReuseToken(t *testing.T) {
+	server, issued := newTokenServer(t, 3600)
+	credentials := newTestOAuth2Credentials(server.URL)
+
+	for i := 0; i < 3; i++ {
+		header, err := credentials.Credentials()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := header.Get("Authorization"); got != "Bearer token-1" {
+			t.Fatalf("expected the cached token, got %q", got)
+		}
+	}
+	if issued.Load() != 1 {
+		t.Fatalf("expected a single token request, got %d", issued.Load())
+	}
+}
+
+func TestOAuth2CredentialsRefreshBeforeExpiry(t *testing.T) {
+	// Tokens expire within the refresh window, so every request gets a new one.
+	server, _ := newTokenServer(t, 30)
+	credentials := newTestOAuth2Credentials(server.URL)
+
+	first, _ := credentials.Credentials()
+	second, _ := credentials.Credentials()
+	if first.Get("Authorization") == second.Get("Authorization") {
+		t.Fatalf("expected the token to be refreshed ahead of its expiry, got %q twice", first.Get("Authorization"))
+	}
+}
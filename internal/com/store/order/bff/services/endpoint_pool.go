@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+)
+
+type EndpointStatus struct {
+	URL                 string     `json:"url"`
+	Healthy             bool       `json:"healthy"`
+	InFlight            int        `json:"inFlight"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	EjectedAt           *time.Time `json:"ejectedAt,omitempty"`
+}
+
+type endpoint struct {
+	url                 string
+	inFlight            int
+	consecutiveFailures int
+	ejected             bool
+	ejectedAt           time.Time
+}
+
+/**
+* endpointPool spreads the calls to the domain API over its replicas, either round-robin or to the replica with the
+* fewest calls in flight. A replica is ejected after ejectAfter consecutive failures (connection errors or 5xx) and
+* then probed every probeInterval, getting back in the pool as soon as it answers a probe with anything below 500.
+* When every replica is ejected, calls are spread over all of them rather than failing outright.
+ */
+type endpointPool struct {
+	leastInFlight bool
+	ejectAfter    int
+	probeInterval time.Duration
+	probePath     string
+	probeClient   *http.Client
+
+	mu        sync.Mutex
+	endpoints []*endpoint
+	next      int
+	probing   bool
+}
+
+func newEndpointPool(cfg *config.Config, urls []string, probeClient *http.Client) *endpointPool {
+	endpoints := make([]*endpoint, len(urls))
+	for i, url := range urls {
+		endpoints[i] = &endpoint{url: url}
+	}
+
+	return &endpointPool{
+		leastInFlight: cfg.BackendLoadBalancing == config.LoadBalancingLeastInFlight,
+		ejectAfter:    max(cfg.BackendEjectAfterFailures, 1),
+		probeInterval: cfg.BackendProbeInterval,
+		probePath:     cfg.BackendProbePath,
+		probeClient:   probeClient,
+		endpoints:     endpoints,
+	}
+}
+
+// acquire picks the endpoint for the next call, which must then be given back with release.
+func (p *endpointPool) acquire() (*endpoint, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.endpoints) == 0 {
+		return nil, errors.New("no domain API endpoint configured")
+	}
+
+	candidates := make([]*endpoint, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		if !e.ejected {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = p.endpoints
+	}
+
+	// Round-robin, also used to break ties between endpoints with as many calls in flight.
+	start := p.next % len(candidates)
+	p.next++
+	chosen := candidates[start]
+	if p.leastInFlight {
+		for i := 1; i < len(candidates); i++ {
+			candidate := candidates[(start+i)%len(candidates)]
+			if candidate.inFlight < chosen.inFlight {
+				chosen = candidate
+			}
+		}
+	}
+
+	chosen.inFlight++
+	return chosen, nil
+}
+
+// release records the outcome of a call, healthy is false when the endpoint failed to answer properly.
+func (p *endpointPool) release(e *endpoint, healthy bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.inFlight--
+
+	if healthy {
+		e.consecutiveFailures = 0
+		return
+	}
+
+	e.consecutiveFailures++
+	if !e.ejected && e.consecutiveFailures >= p.ejectAfter {
+		log.Printf("Ejecting domain API endpoint %s after %d consecutive failures", e.url, e.consecutiveFailures)
+		e.ejected = true
+		e.ejectedAt = time.Now()
+
+		if !p.probing {
+			p.probing = true
+			go p.probe()
+		}
+	}
+}
+
+// probe runs while some endpoints are ejected, putting them back in the pool once they answer again.
+func (p *endpointPool) probe() {
+	ticker := time.NewTicker(p.probeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		p.mu.Lock()
+		var ejected []*endpoint
+		for _, e := range p.endpoints {
+			if e.ejected {
+				ejected = append(ejected, e)
+			}
+		}
+		if len(ejected) == 0 {
+			p.probing = false
+			p.mu.Unlock()
+			return
+		}
+		p.mu.Unlock()
+
+		for _, e := range ejected {
+			if !p.isReachable(e) {
+				continue
+			}
+
+			p.mu.Lock()
+			log.Printf("Domain API endpoint %s answered the probe, putting it back in the pool", e.url)
+			e.ejected = false
+			e.consecutiveFailures = 0
+			p.mu.Unlock()
+		}
+	}
+}
+
+func (p *endpointPool) isReachable(e *endpoint) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), p.probeInterval)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.url+p.probePath, nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := p.probeClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode < http.StatusInternalServerError
+}
+
+func (p *endpointPool) statuses() []EndpointStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]EndpointStatus, len(p.endpoints))
+	for i, e := range p.endpoints {
+		statuses[i] = EndpointStatus{
+			URL:                 e.url,
+			Healthy:             !e.ejected,
+			InFlight:            e.inFlight,
+			ConsecutiveFailures: e.consecutiveFailures,
+		}
+		if e.ejected {
+			ejectedAt := e.ejectedAt
+			statuses[i].EjectedAt = &ejectedAt
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+)
+
+func newTestEndpointPool(loadBalancing string, urls ...string) *endpointPool {
+	cfg := &config.Config{
+		BackendLoadBalancing:      loadBalancing,
+		BackendEjectAfterFailures: 2,
+		BackendProbeInterval:      time.Hour,
+		BackendProbePath:          "/",
+	}
+	return newEndpointPool(cfg, urls, http.DefaultClient)
+}
+
+func TestEndpointPoolEjectsFailingEndpoint(t *testing.T) {
+	pool := newTestEndpointPool(config.LoadBalancingRoundRobin, "http://a", "http://b")
+
+	for i := 0; i < 4; i++ {
+		e, _ := pool.acquire()
+		pool.release(e, e.url != "http://a")
+	}
+
+	for i := 0; i < 3; i++ {
+		e, _ := pool.acquire()
+		if e.url != "http://b" {
+			t.Fatalf("call %d: expected ejected endpoint to be skipped, got %s", i, e.url)
+		}
+		pool.release(e, true)
+	}
+
+	statuses := pool.statuses()
+	if statuses[0].Healthy || statuses[0].EjectedAt == nil {
+		t.Fatalf("expected http://a to be reported as ejected, got %+v", statuses[0])
+	}
+}
+
+func TestEndpointPoolLeastInFlight(t *testing.T) {
+	pool := newTestEndpointPool(config.LoadBalancingLeastInFlight, "http://a", "http://b")
+
+	busy, _ := pool.acquire()
+	for i := 0; i < 3; i++ {
+		e, _ := pool.acquire()
+		if e == busy {
+			t.Fatalf("call %d: expected the idle endpoint, got the busy one %s", i, e.url)
+		}
+		pool.release(e, true)
+	}
+}
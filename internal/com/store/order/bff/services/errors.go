@@ -0,0 +1,106 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Kinds of service errors, check for them with errors.Is.
+var (
+	ErrNotFound            = errors.New("not found")
+	ErrValidation          = errors.New("validation failed")
+	ErrConflict            = errors.New("conflict")
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+)
+
+/**
+* ServiceError is returned by the services when a call cannot be completed. Kind is one of the Err* kinds above (or
+* nil for unexpected failures), Reason describes what went wrong and Err is the underlying cause, if any.
+ */
+type ServiceError struct {
+	Kind   error
+	Reason string
+	Err    error
+}
+
+func (e *ServiceError) Error() string {
+	if e.Err != nil {
+		return e.Reason + ": " + e.Err.Error()
+	}
+	return e.Reason
+}
+
+func (e *ServiceError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// unavailableError wraps a failure to get any response from the domain API (connection error, timeout, open circuit).
+func unavailableError(err error) error {
+	return &ServiceError{Kind: ErrUpstreamUnavailable, Reason: "503 Service Unavailable", Err: err}
+}
+
+// responseError maps an unsuccessful response of the domain API to a ServiceError, keeping the reason it gave.
+func responseError(resp *backendResponse) error {
+	kind := kindForStatus(resp.StatusCode)
+	if kind == ErrUnauthorized {
+		log.Printf("The domain API rejected the credentials of the BFF: %s", resp.Status)
+	}
+	return &ServiceError{Kind: kind, Reason: backendReason(resp)}
+}
+
+// notFoundError is like responseError for a 404, with a message naming the missing resource.
+func notFoundError(resp *backendResponse, format string, args ...any) error {
+	serviceError := &ServiceError{Kind: ErrNotFound, Reason: fmt.Sprintf(format, args...)}
+	if reason := backendReason(resp); reason != resp.Status {
+		serviceError.Err = errors.New(reason)
+	}
+	return serviceError
+}
+
+func kindForStatus(statusCode int) error {
+	switch statusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ErrValidation
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ErrUpstreamUnavailable
+	default:
+		return nil
+	}
+}
+
+/**
+* backendReason extracts the reason from an error response of the domain API. It understands the usual json error
+* bodies ({"message": ...} or {"error": ...}), falls back to a short plain text body and finally to the status line.
+ */
+func backendReason(resp *backendResponse) string {
+	var errorBody struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal(resp.Body, &errorBody); err == nil {
+		if errorBody.Message != "" {
+			return errorBody.Message
+		}
+		if errorBody.Error != "" {
+			return errorBody.Error
+		}
+	}
+
+	text := strings.TrimSpace(string(resp.Body))
+	if text != "" && len(text) <= 200 && !strings.HasPrefix(text, "{") && !strings.HasPrefix(text, "<") {
+		return text
+	}
+
+	return resp.Status
+}
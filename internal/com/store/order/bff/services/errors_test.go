@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestKindForStatus(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       error
+	}{
+		{http.StatusBadRequest, ErrValidation},
+		{http.StatusUnprocessableEntity, ErrValidation},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrUnauthorized},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusConflict, ErrConflict},
+		{http.StatusBadGateway, ErrUpstreamUnavailable},
+		{http.StatusServiceUnavailable, ErrUpstreamUnavailable},
+		{http.StatusGatewayTimeout, ErrUpstreamUnavailable},
+		{http.StatusInternalServerError, nil},
+		{http.StatusTeapot, nil},
+	}
+
+	for _, tt := range tests {
+		if got := kindForStatus(tt.statusCode); got != tt.want {
+			t.Errorf("kindForStatus(%d) = %v, want %v", tt.statusCode, got, tt.want)
+		}
+	}
+}
+
+func TestBackendReason(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"json message", `{"message": "product type is invalid"}`, "product type is invalid"},
+		{"json error", `{"error": "Bad Request", "status": 400}`, "Bad Request"},
+		{"plain text", "  product type is invalid\n", "product type is invalid"},
+		{"json without reason", `{"status": 400}`, "400 Bad Request"},
+		{"html", "<html><body>Bad Request</body></html>", "400 Bad Request"},
+		{"long text", strings.Repeat("x", 201), "400 Bad Request"},
+		{"empty", "", "400 Bad Request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &backendResponse{StatusCode: http.StatusBadRequest, Status: "400 Bad Request", Body: []byte(tt.body)}
+			if got := backendReason(resp); got != tt.want {
+				t.Fatalf("backendReason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseErrorKeepsReason(t *testing.T) {
+	resp := &backendResponse{StatusCode: http.StatusForbidden, Status: "403 Forbidden", Body: []byte(`{"message": "token expired"}`)}
+
+	err := responseError(resp)
+	if !errors.Is(err, ErrUnauthorized) || err.Error() != "token expired" {
+		t.Fatalf("expected an ErrUnauthorized with the backend reason, got %v", err)
+	}
+}
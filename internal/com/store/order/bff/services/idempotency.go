@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+	"fmt"
+)
+
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey attaches the client's Idempotency-Key to ctx, it is then forwarded on POSTs to the domain API.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+func IdempotencyKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyContextKey{}).(string)
+	return key
+}
+
+// deriveIdempotencyKey gives every sub request made on behalf of a single client request its own key.
+func deriveIdempotencyKey(ctx context.Context, format string, args ...any) context.Context {
+	key := IdempotencyKeyFrom(ctx)
+	if key == "" {
+		return ctx
+	}
+	return WithIdempotencyKey(ctx, key+"-"+fmt.Sprintf(format, args...))
+}
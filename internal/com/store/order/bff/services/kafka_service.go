@@ -3,9 +3,13 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/segmentio/kafka-go"
@@ -13,37 +17,259 @@ import (
 	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
 )
 
-func SendProductMessages(products []models.Product) error {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		return fmt.Errorf("error loading config: %w", err)
-	}
-
-	// Create a new Kafka writer with more configuration options
-	w := kafka.NewWriter(kafka.WriterConfig{
-		Brokers:      []string{cfg.KafkaHost + ":" + cfg.KafkaPort},
-		Topic:        cfg.KafkaTopic,
-		Balancer:     &kafka.LeastBytes{},
-		WriteTimeout: 10 * time.Second,
-		ReadTimeout:  10 * time.Second,
-		Async:        false, // Set to true for better performance, but less reliability
-	})
-	defer w.Close()
-
-	if len(products) > 0 {
-		if err := sendSingleProduct(w, products[0]); err != nil {
-			log.Printf("Error sending product (ID: %d): %v", products[0].ID, err)
-			return err
+// ProductPublisher publishes the products returned to clients, for the consumers of the product queries topic.
+type ProductPublisher interface {
+	PublishProducts(ctx context.Context, products []models.Product) error
+}
+
+// OrderEventPublisher publishes the orders created or cancelled through the BFF, for the consumers of the order events
+// topic.
+type OrderEventPublisher interface {
+	PublishOrderEvent(ctx context.Context, event models.OrderEvent) error
+}
+
+// Publisher publishes every kind of message the BFF sends to Kafka.
+type Publisher interface {
+	ProductPublisher
+	OrderEventPublisher
+}
+
+/**
+* KafkaProducer is the single Kafka writer of the application. It is created at startup and shared by every request,
+* so connections to the broker are reused, and must be closed on shutdown to flush what it still holds.
+ */
+type KafkaProducer struct {
+	broker             string
+	topic              string
+	orderEventsTopic   string
+	writer             *kafka.Writer
+	send               func(ctx context.Context, messages ...kafka.Message) error // writer.WriteMessages, replaced in tests
+	maxProductMessages int
+	mode               string
+	stats              publishStats
+	onDelivery         []func(DeliveryReport)
+
+	// When set, messages go through the outbox or the async queue instead of straight to Kafka.
+	outbox *Outbox
+	queue  *publishQueue
+
+	// Order events go through their own queue unless the outbox is used, see PublishOrderEvent.
+	orderEvents *publishQueue
+}
+
+// DeliveryReport is the outcome of writing one message to Kafka, Err is nil once Kafka acknowledged it.
+type DeliveryReport struct {
+	Topic string
+	Key   string
+	Err   error
+}
+
+func NewKafkaProducer(cfg *config.Config) (*KafkaProducer, error) {
+	if cfg.KafkaHost == "" {
+		return nil, fmt.Errorf("KAFKA_HOST is required")
+	}
+	if port, err := strconv.Atoi(cfg.KafkaPort); err != nil || port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("KAFKA_PORT must be a valid port, got '%s'", cfg.KafkaPort)
+	}
+	if cfg.KafkaTopic == "" {
+		return nil, fmt.Errorf("KAFKA_TOPIC is required")
+	}
+	if cfg.KafkaOrderEventsTopic == "" {
+		return nil, fmt.Errorf("KAFKA_ORDER_EVENTS_TOPIC is required")
+	}
+	if cfg.KafkaMaxProductMessages <= 0 {
+		return nil, fmt.Errorf("KAFKA_MAX_PRODUCT_MESSAGES must be positive, got %d", cfg.KafkaMaxProductMessages)
+	}
+
+	broker := net.JoinHostPort(cfg.KafkaHost, cfg.KafkaPort)
+	producer := &KafkaProducer{
+		broker:           broker,
+		topic:            cfg.KafkaTopic,
+		orderEventsTopic: cfg.KafkaOrderEventsTopic,
+		// Every message names its topic, so that events kept in the outbox are relayed where they belong.
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(broker),
+			Balancer:     kafka.Murmur2Balancer{}, // Messages of one key go to one partition, as with the Java client
+			WriteTimeout: 10 * time.Second,
+			ReadTimeout:  10 * time.Second,
+			BatchSize:    cfg.KafkaBatchSize,
+			BatchTimeout: cfg.KafkaLinger,
+			Async:        false, // Async publishing is done by the publish queue, which reports every delivery
+		},
+		maxProductMessages: cfg.KafkaMaxProductMessages,
+		mode:               cfg.KafkaPublishMode,
+	}
+	producer.send = producer.writer.WriteMessages
+	producer.OnDelivery(producer.stats.record)
+	producer.OnDelivery(logDelivery)
+
+	switch {
+	case cfg.KafkaOutboxPath != "":
+		outbox, err := OpenOutbox(cfg)
+		if err != nil {
+			return nil, err
 		}
+		outbox.StartRelay(producer.writeAndDeliver)
+		producer.outbox = outbox
+		producer.mode = "outbox"
+	case cfg.KafkaPublishMode == config.PublishAsync:
+		producer.queue = newPublishQueue(cfg, producer.write, producer.deliver, &producer.stats)
+	}
+
+	if producer.outbox == nil {
+		orderEventsCfg := *cfg
+		orderEventsCfg.KafkaQueueFullPolicy = config.QueueFullDrop
+		producer.orderEvents = newPublishQueue(&orderEventsCfg, producer.write, producer.deliver, &producer.stats)
 	}
 
+	return producer, nil
+}
+
+// OnDelivery registers a callback called with the outcome of every message written to Kafka. Callbacks must be
+// registered before anything is published, and run on the goroutine that wrote the messages.
+func (p *KafkaProducer) OnDelivery(callback func(DeliveryReport)) {
+	p.onDelivery = append(p.onDelivery, callback)
+}
+
+// Stats reports the messages published so far.
+func (p *KafkaProducer) Stats() PublishStats {
+	stats := p.stats.snapshot()
+	stats.Mode = p.mode
+	if p.queue != nil {
+		stats.QueueCapacity = cap(p.queue.messages)
+	}
+	if p.outbox != nil {
+		stats.OutboxPending = p.outbox.Pending()
+		stats.OutboxDeadLetters = p.outbox.DeadLetters()
+	}
+	return stats
+}
+
+// UsesOutbox tells if messages are kept in the outbox, so that they are not lost while Kafka is unavailable.
+func (p *KafkaProducer) UsesOutbox() bool {
+	return p.outbox != nil
+}
+
+// Check makes sure the broker can be reached, so that a misconfigured producer is noticed before serving traffic.
+func (p *KafkaProducer) Check(ctx context.Context) error {
+	conn, err := kafka.DialContext(ctx, "tcp", p.broker)
+	if err != nil {
+		return fmt.Errorf("error connecting to Kafka broker %s: %w", p.broker, err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Brokers(); err != nil {
+		return fmt.Errorf("error reading metadata from Kafka broker %s: %w", p.broker, err)
+	}
 	return nil
 }
 
-func sendSingleProduct(w *kafka.Writer, product models.Product) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// Close flushes the messages still pending and releases the connections to the broker. Events still in the outbox
+// stay there until the next start.
+func (p *KafkaProducer) Close() error {
+	if p.queue != nil {
+		p.queue.close()
+	}
+	if p.orderEvents != nil {
+		p.orderEvents.close()
+	}
+	if p.outbox != nil {
+		if err := p.outbox.Close(); err != nil {
+			log.Printf("Failed to close the outbox: %v", err)
+		}
+	}
+	return p.writer.Close()
+}
+
+func (p *KafkaProducer) write(ctx context.Context, messages ...kafka.Message) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	return p.send(ctx, messages...)
+}
+
+func (p *KafkaProducer) writeAndDeliver(ctx context.Context, messages ...kafka.Message) error {
+	err := p.write(ctx, messages...)
+	p.deliver(messages, err)
+	return err
+}
+
+// deliver reports the outcome of a batch write for each of its messages, a batch can partly fail.
+func (p *KafkaProducer) deliver(messages []kafka.Message, err error) {
+	var writeErrors kafka.WriteErrors
+	perMessage := errors.As(err, &writeErrors) && len(writeErrors) == len(messages)
+
+	for i, message := range messages {
+		report := DeliveryReport{Topic: message.Topic, Key: string(message.Key), Err: err}
+		if perMessage {
+			report.Err = writeErrors[i]
+		}
+		for _, callback := range p.onDelivery {
+			callback(report)
+		}
+	}
+}
+
+func logDelivery(report DeliveryReport) {
+	if report.Err != nil {
+		log.Printf("Error sending message for key %s to %s: %v", report.Key, report.Topic, report.Err)
+		return
+	}
+	log.Printf("Successfully sent message for key %s to %s", report.Key, report.Topic)
+}
+
+// PublishProducts sends one message per product, up to the configured maximum, in a single batch.
+func (p *KafkaProducer) PublishProducts(ctx context.Context, products []models.Product) error {
+	if len(products) > p.maxProductMessages {
+		log.Printf("Publishing the first %d of %d products", p.maxProductMessages, len(products))
+		products = products[:p.maxProductMessages]
+	}
+	if len(products) == 0 {
+		return nil
+	}
+
+	messages := make([]kafka.Message, len(products))
+	for i, product := range products {
+		message, err := p.productMessage(product)
+		if err != nil {
+			return err
+		}
+		messages[i] = message
+	}
+
+	if p.outbox != nil || p.queue != nil {
+		return p.enqueue(ctx, messages...)
+	}
+	return deliveryError(products, p.writeAndDeliver(ctx, messages...))
+}
+
+/**
+* PublishOrderEvent sends the event keyed by order ID, so that the events of an order go to one partition in order.
+* The order already exists by then, so publishing never holds up the request, whatever the publish mode: the event is
+* stored in the outbox, or handed to a queue of its own written by a single worker, which drops it rather than wait
+* when full. Delivery is reported to the OnDelivery callbacks.
+ */
+func (p *KafkaProducer) PublishOrderEvent(ctx context.Context, event models.OrderEvent) error {
+	message, err := p.orderEventMessage(event)
+	if err != nil {
+		return err
+	}
+
+	if p.outbox != nil {
+		return p.outbox.Add(message)
+	}
+	return p.orderEvents.enqueue(ctx, []kafka.Message{message})
+}
+
+// enqueue hands the messages to the outbox or the async queue, which write them to Kafka later on.
+func (p *KafkaProducer) enqueue(ctx context.Context, messages ...kafka.Message) error {
+	if p.outbox != nil {
+		return p.outbox.Add(messages...)
+	}
+	return p.queue.enqueue(ctx, messages)
+}
+
+func (p *KafkaProducer) productMessage(product models.Product) (kafka.Message, error) {
 	productMessage := models.ProductMessage{
 		ID:        product.ID,
 		Name:      product.Name,
@@ -52,17 +278,68 @@ func sendSingleProduct(w *kafka.Writer, product models.Product) error {
 
 	messageValue, err := json.Marshal(productMessage)
 	if err != nil {
-		return fmt.Errorf("error marshaling product message: %w", err)
+		return kafka.Message{}, fmt.Errorf("error marshaling product message: %w", err)
 	}
 
-	err = w.WriteMessages(ctx, kafka.Message{
+	return kafka.Message{
+		Topic: p.topic,
 		Key:   []byte(strconv.Itoa(product.ID)),
 		Value: messageValue,
-	})
+	}, nil
+}
+
+func (p *KafkaProducer) orderEventMessage(event models.OrderEvent) (kafka.Message, error) {
+	messageValue, err := json.Marshal(event)
 	if err != nil {
-		return fmt.Errorf("error writing message to Kafka: %w", err)
+		return kafka.Message{}, fmt.Errorf("error marshaling order event: %w", err)
 	}
 
-	log.Printf("Successfully sent product message for ID: %d", product.ID)
-	return nil
+	return kafka.Message{
+		Topic: p.orderEventsTopic,
+		Key:   []byte(strconv.Itoa(event.OrderID)),
+		Value: messageValue,
+	}, nil
+}
+
+// ProductPublishError lists the products whose message was not written to Kafka, the others were.
+type ProductPublishError struct {
+	Failed map[int]error
+}
+
+func (e *ProductPublishError) Error() string {
+	ids := make([]int, 0, len(e.Failed))
+	for id := range e.Failed {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	failures := make([]string, len(ids))
+	for i, id := range ids {
+		failures[i] = fmt.Sprintf("%d (%v)", id, e.Failed[id])
+	}
+	return "error writing messages to Kafka for products " + strings.Join(failures, ", ")
+}
+
+// deliveryError maps the outcome of a batch write to the products it was for. A batch can partly fail, in which case
+// kafka-go reports an error per message.
+func deliveryError(products []models.Product, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	failed := make(map[int]error)
+	var writeErrors kafka.WriteErrors
+	if errors.As(err, &writeErrors) && len(writeErrors) == len(products) {
+		for i, writeErr := range writeErrors {
+			if writeErr != nil {
+				failed[products[i].ID] = writeErr
+			}
+		}
+	} else {
+		for _, product := range products {
+			failed[product.ID] = err
+		}
+	}
+
+	return &ProductPublishError{Failed: failed}
 }
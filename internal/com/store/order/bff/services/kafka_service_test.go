@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
+)
+
+func TestDeliveryErrorReportsFailedProducts(t *testing.T) {
+	products := []models.Product{{ID: 1}, {ID: 2}, {ID: 3}}
+	writeErr := errors.New("leader not available")
+
+	err := deliveryError(products, kafka.WriteErrors{nil, writeErr, nil})
+
+	var publishErr *ProductPublishError
+	if !errors.As(err, &publishErr) {
+		t.Fatalf("expected a ProductPublishError, got %v", err)
+	}
+	if len(publishErr.Failed) != 1 || publishErr.Failed[2] != writeErr {
+		t.Fatalf("expected only product 2 to have failed, got %v", publishErr.Failed)
+	}
+}
+
+func TestDeliveryErrorFailsWholeBatch(t *testing.T) {
+	products := []models.Product{{ID: 1}, {ID: 2}}
+
+	err := deliveryError(products, errors.New("connection refused"))
+
+	var publishErr *ProductPublishError
+	if !errors.As(err, &publishErr) || len(publishErr.Failed) != 2 {
+		t.Fatalf("expected every product to have failed, got %v", err)
+	}
+	if deliveryError(products, nil) != nil {
+		t.Fatal("expected no error for a successful batch")
+	}
+}
+
+func TestOrderEventMessageIsKeyedByOrderID(t *testing.T) {
+	producer := &KafkaProducer{topic: "product-queries", orderEventsTopic: "order-events"}
+	event := models.NewOrderEvent(models.OrderEventCancelled, models.Order{ID: 42, ProductID: 7, Count: 2, Status: models.OrderStatusCancelled})
+
+	message, err := producer.orderEventMessage(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Topic != "order-events" || string(message.Key) != "42" {
+		t.Fatalf("expected a message keyed 42 on order-events, got key %s on %s", message.Key, message.Topic)
+	}
+
+	var value map[string]any
+	if err := json.Unmarshal(message.Value, &value); err != nil {
+		t.Fatal(err)
+	}
+	if value["type"] != "order-cancelled" || value["orderid"] != 42.0 || value["status"] != "cancelled" {
+		t.Fatalf("unexpected order event %s", message.Value)
+	}
+}
+
+func TestProducerKeepsMessagesOfAKeyOnOnePartition(t *testing.T) {
+	producer, err := NewKafkaProducer(&config.Config{
+		KafkaHost:               "localhost",
+		KafkaPort:               "9092",
+		KafkaTopic:              "product-queries",
+		KafkaOrderEventsTopic:   "order-events",
+		KafkaMaxProductMessages: 10,
+		KafkaPublishMode:        config.PublishSync,
+		KafkaBatchSize:          10,
+		KafkaLinger:             time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	partitions := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	balancer := producer.writer.Balancer
+	for _, key := range []string{"1", "2", "42"} {
+		partition := balancer.Balance(kafka.Message{Key: []byte(key), Value: []byte("first")}, partitions...)
+
+		// Interleaved with messages of other keys and sizes, which a balancer ignoring keys would spread around.
+		for i := 0; i < 20; i++ {
+			balancer.Balance(kafka.Message{Key: []byte("other"), Value: make([]byte, i*100)}, partitions...)
+			if got := balancer.Balance(kafka.Message{Key: []byte(key), Value: make([]byte, i)}, partitions...); got != partition {
+				t.Fatalf("expected every message of key %s on partition %d, got %d", key, partition, got)
+			}
+		}
+	}
+}
+
+// newRecordingProducer returns a sync producer whose Kafka writes are recorded, and answered with writeErr.
+func newRecordingProducer(t *testing.T, maxProductMessages int, writeErr error) (*KafkaProducer, *[][]kafka.Message) {
+	producer, err := NewKafkaProducer(&config.Config{
+		KafkaHost:               "localhost",
+		KafkaPort:               "9092",
+		KafkaTopic:              "product-queries",
+		KafkaOrderEventsTopic:   "order-events",
+		KafkaMaxProductMessages: maxProductMessages,
+		KafkaPublishMode:        config.PublishSync,
+		KafkaQueueSize:          10,
+		KafkaBatchSize:          10,
+		KafkaLinger:             time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { producer.Close() })
+
+	var batches [][]kafka.Message
+	producer.send = func(_ context.Context, messages ...kafka.Message) error {
+		batches = append(batches, messages)
+		return writeErr
+	}
+	return producer, &batches
+}
+
+func TestPublishProductsSendsOneBatch(t *testing.T) {
+	producer, batches := newRecordingProducer(t, 10, nil)
+	products := []models.Product{
+		{ID: 1, Name: "iPhone", Type: models.TypeGadget, Inventory: 10},
+		{ID: 2, Name: "Macbook", Type: models.TypeGadget, Inventory: 5},
+		{ID: 3, Name: "iPad", Type: models.TypeGadget, Inventory: 7},
+	}
+
+	if err := producer.PublishProducts(context.Background(), products); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*batches) != 1 || len((*batches)[0]) != 3 {
+		t.Fatalf("expected a single batch of 3 messages, got %d batches", len(*batches))
+	}
+	for i, message := range (*batches)[0] {
+		var value models.ProductMessage
+		if err := json.Unmarshal(message.Value, &value); err != nil {
+			t.Fatal(err)
+		}
+		if message.Topic != "product-queries" || string(message.Key) != strconv.Itoa(products[i].ID) || value.ID != products[i].ID || value.Name != products[i].Name || value.Inventory != products[i].Inventory {
+			t.Fatalf("unexpected message %d: key %s on %s, %s", i, message.Key, message.Topic, message.Value)
+		}
+	}
+	if stats := producer.Stats(); stats.Delivered != 3 {
+		t.Fatalf("expected 3 deliveries, got %+v", stats)
+	}
+}
+
+func TestPublishProductsCapsMessages(t *testing.T) {
+	producer, batches := newRecordingProducer(t, 2, nil)
+	products := []models.Product{{ID: 1}, {ID: 2}, {ID: 3}}
+
+	if err := producer.PublishProducts(context.Background(), products); err != nil {
+		t.Fatal(err)
+	}
+	if len(*batches) != 1 || len((*batches)[0]) != 2 || string((*batches)[0][1].Key) != "2" {
+		t.Fatalf("expected the first 2 products in a single batch, got %v", *batches)
+	}
+
+	if err := producer.PublishProducts(context.Background(), nil); err != nil || len(*batches) != 1 {
+		t.Fatalf("expected nothing to be written for no product, got %v after %d batches", err, len(*batches))
+	}
+}
+
+func TestPublishProductsReportsFailedProducts(t *testing.T) {
+	writeErr := errors.New("leader not available")
+	producer, _ := newRecordingProducer(t, 10, kafka.WriteErrors{nil, writeErr})
+
+	err := producer.PublishProducts(context.Background(), []models.Product{{ID: 1}, {ID: 2}})
+
+	var publishErr *ProductPublishError
+	if !errors.As(err, &publishErr) || len(publishErr.Failed) != 1 || publishErr.Failed[2] != writeErr {
+		t.Fatalf("expected only product 2 to have failed, got %v", err)
+	}
+}
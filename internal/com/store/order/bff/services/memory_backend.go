@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
+)
+
+/**
+* InMemoryBackend is a fake domain API keeping products and orders in memory. IDs are assigned incrementally and
+* failures can be injected per operation with FailOn, using the same operation names as the circuit breakers
+* (getProducts, getProduct, createProduct, updateProduct, deleteProduct, createOrder, getOrder, getOrders, updateOrder).
+ */
+type InMemoryBackend struct {
+	mu            sync.Mutex
+	products      map[int]models.Product
+	orders        map[int]models.Order
+	nextProductID int
+	nextOrderID   int
+	failures      map[string]error
+}
+
+// NewInMemoryBackend creates a backend seeded with the given products, new products get IDs after the highest seeded one.
+func NewInMemoryBackend(products ...models.Product) *InMemoryBackend {
+	b := &InMemoryBackend{
+		products:      make(map[int]models.Product),
+		orders:        make(map[int]models.Order),
+		nextProductID: 1,
+		nextOrderID:   1,
+		failures:      make(map[string]error),
+	}
+
+	for _, product := range products {
+		b.products[product.ID] = product
+		if product.ID >= b.nextProductID {
+			b.nextProductID = product.ID + 1
+		}
+	}
+
+	return b
+}
+
+// SampleProducts is the catalogue used when running the BFF against the in-memory backend.
+func SampleProducts() []models.Product {
+	return []models.Product{
+		{ID: 1, Name: "iPhone", Type: models.TypeGadget, Inventory: 10},
+		{ID: 2, Name: "Macbook", Type: models.TypeGadget, Inventory: 5},
+		{ID: 3, Name: "Harry Potter", Type: models.TypeBook, Inventory: 50},
+		{ID: 4, Name: "The Hobbit", Type: models.TypeBook, Inventory: 20},
+		{ID: 5, Name: "Mango", Type: models.TypeFood, Inventory: 100},
+	}
+}
+
+// FailOn makes every call to the operation return err, until it is called again with a nil err.
+func (b *InMemoryBackend) FailOn(operation string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		delete(b.failures, operation)
+		return
+	}
+	b.failures[operation] = err
+}
+
+// Orders returns every order created so far, sorted by ID.
+func (b *InMemoryBackend) Orders() []models.Order {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return sortedByID(b.orders, func(order models.Order) int { return order.ID })
+}
+
+func (b *InMemoryBackend) GetAllProducts(ctx context.Context, productType string, pageRequest models.PageRequest) (models.Page[models.Product], error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.failure("getProducts"); err != nil {
+		return models.Page[models.Product]{}, err
+	}
+
+	products := []models.Product{}
+	for _, product := range sortedByID(b.products, func(product models.Product) int { return product.ID }) {
+		if string(product.Type) == productType {
+			products = append(products, product)
+		}
+	}
+
+	return models.Paginate(products, pageRequest), nil
+}
+
+func (b *InMemoryBackend) GetProduct(ctx context.Context, productID int) (models.Product, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.failure("getProduct"); err != nil {
+		return models.Product{}, err
+	}
+
+	product, exists := b.products[productID]
+	if !exists {
+		return models.Product{}, &ServiceError{Kind: ErrNotFound, Reason: fmt.Sprintf("product with id %d not found", productID)}
+	}
+	return product, nil
+}
+
+func (b *InMemoryBackend) CreateProduct(ctx context.Context, newProduct models.NewProduct) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.failure("createProduct"); err != nil {
+		return -1, err
+	}
+
+	product := newProduct.ToProduct(b.nextProductID)
+	b.products[product.ID] = product
+	b.nextProductID++
+
+	return product.ID, nil
+}
+
+func (b *InMemoryBackend) UpdateProduct(ctx context.Context, product models.Product) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.failure("updateProduct"); err != nil {
+		return err
+	}
+
+	if _, exists := b.products[product.ID]; !exists {
+		return &ServiceError{Kind: ErrNotFound, Reason: fmt.Sprintf("product with id %d not found", product.ID)}
+	}
+	b.products[product.ID] = product
+	return nil
+}
+
+func (b *InMemoryBackend) DeleteProduct(ctx context.Context, productID int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.failure("deleteProduct"); err != nil {
+		return err
+	}
+
+	if _, exists := b.products[productID]; !exists {
+		return &ServiceError{Kind: ErrNotFound, Reason: fmt.Sprintf("product with id %d not found", productID)}
+	}
+	delete(b.products, productID)
+	return nil
+}
+
+func (b *InMemoryBackend) CreateOrder(ctx context.Context, orderRequest models.OrderRequest) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.failure("createOrder"); err != nil {
+		return -1, err
+	}
+
+	product, exists := b.products[orderRequest.ProductID]
+	if !exists {
+		return -1, &ServiceError{Kind: ErrNotFound, Reason: fmt.Sprintf("product with id %d not found", orderRequest.ProductID)}
+	}
+
+	if err := checkInventory(product, orderRequest.Count); err != nil {
+		return -1, err
+	}
+
+	order := models.Order{
+		ID:        b.nextOrderID,
+		ProductID: orderRequest.ProductID,
+		Count:     orderRequest.Count,
+		Status:    models.OrderStatusPending,
+	}
+	b.orders[order.ID] = order
+	b.nextOrderID++
+
+	return order.ID, nil
+}
+
+func (b *InMemoryBackend) GetOrder(ctx context.Context, orderID int) (models.Order, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.failure("getOrder"); err != nil {
+		return models.Order{}, err
+	}
+
+	order, exists := b.orders[orderID]
+	if !exists {
+		return models.Order{}, &ServiceError{Kind: ErrNotFound, Reason: fmt.Sprintf("order with id %d not found", orderID)}
+	}
+	return order, nil
+}
+
+func (b *InMemoryBackend) GetOrders(ctx context.Context, query models.OrderQuery, pageRequest models.PageRequest) (models.Page[models.Order], error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.failure("getOrders"); err != nil {
+		return models.Page[models.Order]{}, err
+	}
+
+	orders := []models.Order{}
+	for _, order := range sortedByID(b.orders, func(order models.Order) int { return order.ID }) {
+		if query.Status != "" && order.Status != query.Status {
+			continue
+		}
+		if query.ProductID != 0 && order.ProductID != query.ProductID {
+			continue
+		}
+		orders = append(orders, order)
+	}
+
+	return models.Paginate(orders, pageRequest), nil
+}
+
+func (b *InMemoryBackend) UpdateOrder(ctx context.Context, order models.Order) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.failure("updateOrder"); err != nil {
+		return err
+	}
+
+	if _, exists := b.orders[order.ID]; !exists {
+		return &ServiceError{Kind: ErrNotFound, Reason: fmt.Sprintf("order with id %d not found", order.ID)}
+	}
+	b.orders[order.ID] = order
+	return nil
+}
+
+// failure must be called with the lock held.
+func (b *InMemoryBackend) failure(operation string) error {
+	return b.failures[operation]
+}
+
+func sortedByID[T any](items map[int]T, id func(T) int) []T {
+	sorted := make([]T, 0, len(items))
+	for _, item := range items {
+		sorted = append(sorted, item)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return id(sorted[i]) < id(sorted[j]) })
+	return sorted
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
+)
+
+type recordingPublisher struct {
+	mu             sync.Mutex
+	events         []models.OrderEvent
+	productBatches int
+}
+
+func (p *recordingPublisher) PublishProducts(context.Context, []models.Product) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.productBatches++
+	return nil
+}
+
+func (p *recordingPublisher) PublishOrderEvent(_ context.Context, event models.OrderEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+func TestBackendPublishesOrderEvents(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/products/7":
+			w.Write([]byte(`{"id":7,"name":"iPhone","type":"gadget","inventory":10}`))
+		case "/orders":
+			w.Write([]byte(`{"id":42}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer stub.Close()
+
+	publisher := &recordingPublisher{}
+	backend, err := NewBackendService(&config.Config{
+		BackendProbeInterval:    time.Hour,
+		BackendRetryMaxAttempts: 1,
+		BackendReadTimeout:      time.Second,
+		BackendWriteTimeout:     time.Second,
+	}, []string{stub.URL}, StaticToken("API-TOKEN-SPEC"), publisher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orderID, err := backend.CreateOrder(context.Background(), models.OrderRequest{ProductID: 7, Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.UpdateOrder(context.Background(), models.Order{ID: orderID, ProductID: 7, Count: 2, Status: models.OrderStatusCancelled}); err != nil {
+		t.Fatal(err)
+	}
+	// Other status changes are not published.
+	if err := backend.UpdateOrder(context.Background(), models.Order{ID: orderID, ProductID: 7, Count: 2, Status: models.OrderStatusFulfilled}); err != nil {
+		t.Fatal(err)
+	}
+
+	events := publisher.events
+	if len(events) != 2 {
+		t.Fatalf("expected 2 order events, got %+v", events)
+	}
+	if events[0].Type != models.OrderEventCreated || events[0].OrderID != 42 || events[0].Status != models.OrderStatusPending {
+		t.Fatalf("unexpected order-created event %+v", events[0])
+	}
+	if events[1].Type != models.OrderEventCancelled || events[1].OrderID != 42 || events[1].Count != 2 {
+		t.Fatalf("unexpected order-cancelled event %+v", events[1])
+	}
+}
+
+func TestOrderEventsDoNotWaitForKafka(t *testing.T) {
+	producer, err := NewKafkaProducer(&config.Config{
+		KafkaHost:               "127.0.0.1",
+		KafkaPort:               "1", // nothing listens there
+		KafkaTopic:              "product-queries",
+		KafkaOrderEventsTopic:   "order-events",
+		KafkaMaxProductMessages: 10,
+		KafkaPublishMode:        config.PublishSync,
+		KafkaQueueSize:          10,
+		KafkaBatchSize:          10,
+		KafkaLinger:             time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer.writer.MaxAttempts = 1
+
+	reports := make(chan DeliveryReport, 2)
+	producer.OnDelivery(func(report DeliveryReport) { reports <- report })
+
+	start := time.Now()
+	for _, eventType := range []models.OrderEventType{models.OrderEventCreated, models.OrderEventCancelled} {
+		if err := producer.PublishOrderEvent(context.Background(), models.NewOrderEvent(eventType, models.Order{ID: 42})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("expected publishing order events not to wait for Kafka, took %v", elapsed)
+	}
+
+	// Close waits for the events to be written, which fails here.
+	producer.Close()
+	for i := 0; i < 2; i++ {
+		if report := <-reports; report.Err == nil || report.Topic != "order-events" || report.Key != "42" {
+			t.Fatalf("expected a failed delivery of order 42 on order-events, got %+v", report)
+		}
+	}
+}
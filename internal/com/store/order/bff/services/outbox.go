@@ -0,0 +1,414 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	outboxBucket      = []byte("events")
+	deadLettersBucket = []byte("dead-letters")
+)
+
+// outboxEvent is a Kafka message waiting in the outbox, stored as json under its sequence number.
+type outboxEvent struct {
+	Topic     string    `json:"topic"`
+	Key       []byte    `json:"key"`
+	Value     []byte    `json:"value"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Attempts Kafka refused, the event is not relayed again before NextAttemptAt.
+	Attempts      int       `json:"attempts,omitempty"`
+	NextAttemptAt time.Time `json:"nextAttemptAt,omitempty"`
+	LastError     string    `json:"lastError,omitempty"`
+}
+
+// deadLetter is an event set aside for good, kept as it was stored in the outbox along with why it was set aside.
+type deadLetter struct {
+	Event  []byte    `json:"event"`
+	Reason string    `json:"reason"`
+	DeadAt time.Time `json:"deadAt"`
+}
+
+type storedEvent struct {
+	seq   []byte
+	event outboxEvent
+}
+
+/**
+* Outbox is a durable queue of Kafka messages in an embedded file-based store. Events are stored as soon as they are
+* produced and relayed to Kafka in the background, so producing an event never depends on the broker being up, and an
+* event is only removed once Kafka has acknowledged it.
+*
+* Events are relayed in the order they were stored. A failed message is retried before any later one of the same key:
+* the producer hashes keys to partitions, so messages of one key go to the same partition and succeed or fail together
+* within a batch, and later messages of a key wait while an earlier one backs off.
+*
+* While Kafka can't be reached the whole relay backs off and retries indefinitely. An event Kafka itself refuses (a
+* missing topic, a message too large, ...) backs off on its own instead, so the others keep flowing, and is moved to
+* the dead letters after maxAttempts. So are events that can't be decoded anymore.
+ */
+type Outbox struct {
+	db           *bolt.DB
+	batchSize    int
+	pollInterval time.Duration
+	maxAttempts  int
+	retryPolicy  RetryPolicy
+
+	added  chan struct{}
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+func OpenOutbox(cfg *config.Config) (*Outbox, error) {
+	db, err := bolt.Open(cfg.KafkaOutboxPath, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening outbox %s: %w", cfg.KafkaOutboxPath, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(outboxBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(deadLettersBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error initialising outbox %s: %w", cfg.KafkaOutboxPath, err)
+	}
+
+	return &Outbox{
+		db:           db,
+		batchSize:    cfg.KafkaOutboxBatchSize,
+		pollInterval: cfg.KafkaOutboxPollInterval,
+		maxAttempts:  cfg.KafkaOutboxMaxAttempts,
+		retryPolicy: RetryPolicy{
+			InitialBackoff: cfg.KafkaOutboxInitialBackoff,
+			MaxBackoff:     cfg.KafkaOutboxMaxBackoff,
+			Jitter:         0.2,
+		},
+		added: make(chan struct{}, 1),
+	}, nil
+}
+
+// Add stores messages in a single transaction, they are either all relayed eventually or none is stored.
+func (o *Outbox) Add(messages ...kafka.Message) error {
+	err := o.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(outboxBucket)
+		for _, message := range messages {
+			seq, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			value, err := json.Marshal(outboxEvent{Topic: message.Topic, Key: message.Key, Value: message.Value, CreatedAt: time.Now()})
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(sequenceKey(seq), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error adding events to the outbox: %w", err)
+	}
+
+	// Wake up the relay, unless it already has a pending wake up.
+	select {
+	case o.added <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pending returns the number of events not relayed to Kafka yet.
+func (o *Outbox) Pending() int {
+	var pending int
+	o.db.View(func(tx *bolt.Tx) error {
+		pending = tx.Bucket(outboxBucket).Stats().KeyN
+		return nil
+	})
+	return pending
+}
+
+// DeadLetters returns the number of events set aside because Kafka kept refusing them or they could not be decoded.
+func (o *Outbox) DeadLetters() int {
+	var deadLetters int
+	o.db.View(func(tx *bolt.Tx) error {
+		deadLetters = tx.Bucket(deadLettersBucket).Stats().KeyN
+		return nil
+	})
+	return deadLetters
+}
+
+// StartRelay relays the events to Kafka with write until Close is called.
+func (o *Outbox) StartRelay(write func(ctx context.Context, messages ...kafka.Message) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	o.cancel = cancel
+
+	o.done.Add(1)
+	go func() {
+		defer o.done.Done()
+		o.relay(ctx, write)
+	}()
+}
+
+func (o *Outbox) relay(ctx context.Context, write func(ctx context.Context, messages ...kafka.Message) error) {
+	failures := 0
+	for {
+		attempted, err := o.relayBatch(ctx, write)
+
+		switch {
+		case err != nil:
+			// Back off while Kafka is failing, however many events come in meanwhile.
+			failures++
+			log.Printf("Failed to relay outbox events to Kafka (attempt %d), will retry: %v", failures, err)
+			if o.retryPolicy.wait(ctx, failures) != nil {
+				return
+			}
+		case attempted == o.batchSize:
+			// There may be more waiting.
+			failures = 0
+		default:
+			failures = 0
+			select {
+			case <-ctx.Done():
+				return
+			case <-o.added:
+			case <-time.After(o.pollInterval):
+			}
+		}
+	}
+}
+
+/**
+* relayBatch writes the oldest events due to Kafka, one write per topic, and returns how many were attempted. The ones
+* Kafka acknowledged are removed and the ones it refused are held back. It only fails when Kafka could not be reached.
+ */
+func (o *Outbox) relayBatch(ctx context.Context, write func(ctx context.Context, messages ...kafka.Message) error) (int, error) {
+	events, corrupt, err := o.dueEvents()
+	if err != nil {
+		return 0, err
+	}
+
+	var delivered, refused []storedEvent
+	var unreachable error
+	for _, topicEvents := range byTopic(events) {
+		for i, writeErr := range writeEvents(ctx, write, topicEvents) {
+			switch {
+			case writeErr == nil:
+				delivered = append(delivered, topicEvents[i])
+			case refusedByKafka(writeErr):
+				topicEvents[i].event.LastError = writeErr.Error()
+				refused = append(refused, topicEvents[i])
+			default:
+				// Left as it is, to be retried with the rest once Kafka is back.
+				unreachable = writeErr
+			}
+		}
+	}
+
+	if err := o.settle(delivered, refused, corrupt); err != nil {
+		return 0, err
+	}
+	if len(delivered) == 0 && len(refused) == 0 && unreachable != nil {
+		return 0, unreachable
+	}
+	return len(delivered) + len(refused), nil
+}
+
+type corruptEvent struct {
+	seq   []byte
+	value []byte
+	err   error
+}
+
+// dueEvents returns the oldest events that are due, skipping the ones backing off along with every later event of the
+// same key, and the events that could not be decoded.
+func (o *Outbox) dueEvents() ([]storedEvent, []corruptEvent, error) {
+	var events []storedEvent
+	var corrupt []corruptEvent
+	now := time.Now()
+	backingOff := make(map[string]bool)
+
+	err := o.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(outboxBucket).Cursor()
+		for k, v := cursor.First(); k != nil && len(events) < o.batchSize; k, v = cursor.Next() {
+			seq := append([]byte(nil), k...)
+
+			var event outboxEvent
+			if err := json.Unmarshal(v, &event); err != nil {
+				corrupt = append(corrupt, corruptEvent{seq: seq, value: append([]byte(nil), v...), err: err})
+				continue
+			}
+
+			orderingKey := event.Topic + "\x00" + string(event.Key)
+			if backingOff[orderingKey] || now.Before(event.NextAttemptAt) {
+				backingOff[orderingKey] = true
+				continue
+			}
+			events = append(events, storedEvent{seq: seq, event: event})
+		}
+		return nil
+	})
+	return events, corrupt, err
+}
+
+// byTopic groups the events by topic, keeping their order within each topic.
+func byTopic(events []storedEvent) [][]storedEvent {
+	var groups [][]storedEvent
+	groupOf := make(map[string]int)
+	for _, event := range events {
+		i, exists := groupOf[event.event.Topic]
+		if !exists {
+			i = len(groups)
+			groupOf[event.event.Topic] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], event)
+	}
+	return groups
+}
+
+// errNotWritten is the outcome of the messages of a write given up before they were sent.
+var errNotWritten = errors.New("not written")
+
+// writeEvents writes the events in a single batch and returns the outcome of each, nil once Kafka acknowledged it.
+func writeEvents(ctx context.Context, write func(ctx context.Context, messages ...kafka.Message) error, events []storedEvent) []error {
+	messages := make([]kafka.Message, len(events))
+	for i, stored := range events {
+		messages[i] = kafka.Message{Topic: stored.event.Topic, Key: stored.event.Key, Value: stored.event.Value}
+	}
+
+	err := write(ctx, messages...)
+
+	outcomes := make([]error, len(events))
+	var writeErrors kafka.WriteErrors
+	var tooLarge kafka.MessageTooLargeError
+	switch {
+	case err == nil:
+	case errors.As(err, &writeErrors) && len(writeErrors) == len(events):
+		copy(outcomes, writeErrors)
+	case errors.As(err, &tooLarge):
+		// kafka-go gives up on the whole batch, but only the message too large is to blame.
+		for i := range outcomes {
+			outcomes[i] = errNotWritten
+		}
+		outcomes[len(events)-len(tooLarge.Remaining)-1] = err
+	default:
+		for i := range outcomes {
+			outcomes[i] = err
+		}
+	}
+	return outcomes
+}
+
+// refusedByKafka tells if Kafka answered and refused the message, rather than could not be reached at all.
+func refusedByKafka(err error) bool {
+	var kafkaErr kafka.Error
+	var tooLarge kafka.MessageTooLargeError
+	return errors.As(err, &kafkaErr) || errors.As(err, &tooLarge)
+}
+
+/**
+* settle removes the delivered events, holds back the refused ones until their next attempt, or moves them to the dead
+* letters once out of attempts, and moves the corrupt ones to the dead letters, all in a single transaction.
+ */
+func (o *Outbox) settle(delivered, refused []storedEvent, corrupt []corruptEvent) error {
+	if len(delivered) == 0 && len(refused) == 0 && len(corrupt) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	err := o.db.Update(func(tx *bolt.Tx) error {
+		events := tx.Bucket(outboxBucket)
+		deadLetters := tx.Bucket(deadLettersBucket)
+
+		for _, stored := range delivered {
+			if err := events.Delete(stored.seq); err != nil {
+				return err
+			}
+		}
+
+		for _, stored := range refused {
+			event := stored.event
+			event.Attempts++
+			seq := binary.BigEndian.Uint64(stored.seq)
+
+			if event.Attempts >= o.maxAttempts {
+				log.Printf("Moving outbox event %d to the dead letters after %d attempts: %s", seq, event.Attempts, event.LastError)
+				value, err := json.Marshal(event)
+				if err != nil {
+					return err
+				}
+				if err := moveToDeadLetters(events, deadLetters, stored.seq, value, event.LastError, now); err != nil {
+					return err
+				}
+				continue
+			}
+
+			log.Printf("Kafka refused outbox event %d (attempt %d of %d), will retry: %s", seq, event.Attempts, o.maxAttempts, event.LastError)
+			event.NextAttemptAt = now.Add(o.retryPolicy.backoff(event.Attempts))
+			value, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if err := events.Put(stored.seq, value); err != nil {
+				return err
+			}
+		}
+
+		for _, event := range corrupt {
+			log.Printf("Moving corrupt outbox event %d to the dead letters: %v", binary.BigEndian.Uint64(event.seq), event.err)
+			if err := moveToDeadLetters(events, deadLetters, event.seq, event.value, "corrupt event: "+event.err.Error(), now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error updating the outbox: %w", err)
+	}
+	return nil
+}
+
+// moveToDeadLetters keeps the event under its sequence number in the dead letters, where it is no longer relayed.
+func moveToDeadLetters(events, deadLetters *bolt.Bucket, seq, value []byte, reason string, now time.Time) error {
+	letter, err := json.Marshal(deadLetter{Event: value, Reason: reason, DeadAt: now})
+	if err != nil {
+		return err
+	}
+	if err := deadLetters.Put(seq, letter); err != nil {
+		return err
+	}
+	return events.Delete(seq)
+}
+
+// Close stops the relay and closes the store, events not relayed yet are relayed after the next start.
+func (o *Outbox) Close() error {
+	if o.cancel != nil {
+		o.cancel()
+		o.done.Wait()
+	}
+	return o.db.Close()
+}
+
+// sequenceKey encodes sequence numbers big-endian, so that the store keeps events in the order they were added.
+func sequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestOutbox(t *testing.T, path string) *Outbox {
+	outbox, err := OpenOutbox(&config.Config{
+		KafkaOutboxPath:           path,
+		KafkaOutboxBatchSize:      10,
+		KafkaOutboxPollInterval:   10 * time.Millisecond,
+		KafkaOutboxInitialBackoff: time.Millisecond,
+		KafkaOutboxMaxBackoff:     5 * time.Millisecond,
+		KafkaOutboxMaxAttempts:    3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return outbox
+}
+
+// fakeKafka can't be reached for the first writes, then records every message it accepts.
+type fakeKafka struct {
+	mu        sync.Mutex
+	failures  int
+	partial   bool
+	missing   string
+	delivered []string
+}
+
+func (k *fakeKafka) write(_ context.Context, messages ...kafka.Message) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.failures > 0 {
+		k.failures--
+		return errors.New("dial tcp: connection refused")
+	}
+
+	// Like kafka-go, fails the whole write when a topic does not exist.
+	for _, message := range messages {
+		if k.missing != "" && message.Topic == k.missing {
+			return kafka.UnknownTopicOrPartition
+		}
+	}
+
+	// Rejects the messages of key "b" once, as a partition leader going away would.
+	var writeErrors kafka.WriteErrors
+	for _, message := range messages {
+		if k.partial && string(message.Key) == "b" {
+			writeErrors = append(writeErrors, kafka.NotLeaderForPartition)
+			continue
+		}
+		writeErrors = append(writeErrors, nil)
+		k.delivered = append(k.delivered, string(message.Value))
+	}
+	if k.partial {
+		k.partial = false
+		return writeErrors
+	}
+	return nil
+}
+
+func (k *fakeKafka) deliveredMessages() []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([]string(nil), k.delivered...)
+}
+
+func waitForEmptyOutbox(t *testing.T, outbox *Outbox) {
+	deadline := time.Now().Add(2 * time.Second)
+	for outbox.Pending() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("outbox still has %d events", outbox.Pending())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestOutboxRelaysInOrderPerKeyAfterFailures(t *testing.T) {
+	outbox := openTestOutbox(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer outbox.Close()
+
+	broker := &fakeKafka{failures: 2, partial: true}
+	err := outbox.Add(
+		kafka.Message{Key: []byte("a"), Value: []byte("a1")},
+		kafka.Message{Key: []byte("b"), Value: []byte("b1")},
+		kafka.Message{Key: []byte("a"), Value: []byte("a2")},
+		kafka.Message{Key: []byte("b"), Value: []byte("b2")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox.StartRelay(broker.write)
+	waitForEmptyOutbox(t, outbox)
+
+	// The messages of b failed once and were retried after those of a, but still in their own order.
+	want := []string{"a1", "a2", "b1", "b2"}
+	got := broker.deliveredMessages()
+	if len(got) != len(want) {
+		t.Fatalf("expected %v to be delivered, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v to be delivered, got %v", want, got)
+		}
+	}
+}
+
+func TestOutboxKeepsEventsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+
+	outbox := openTestOutbox(t, path)
+	if err := outbox.Add(kafka.Message{Key: []byte("1"), Value: []byte("product 1")}); err != nil {
+		t.Fatal(err)
+	}
+	outbox.StartRelay((&fakeKafka{failures: 1000}).write)
+	time.Sleep(20 * time.Millisecond)
+	outbox.Close()
+
+	outbox = openTestOutbox(t, path)
+	defer outbox.Close()
+	if outbox.Pending() != 1 || outbox.DeadLetters() != 0 {
+		t.Fatalf("expected the undelivered event to survive the outage and the restart, got %d pending and %d dead letters", outbox.Pending(), outbox.DeadLetters())
+	}
+
+	broker := &fakeKafka{}
+	outbox.StartRelay(broker.write)
+	waitForEmptyOutbox(t, outbox)
+	if got := broker.deliveredMessages(); len(got) != 1 || got[0] != "product 1" {
+		t.Fatalf("expected the event to be relayed after the restart, got %v", got)
+	}
+}
+
+func TestOutboxDeadLettersEventsKafkaKeepsRefusing(t *testing.T) {
+	outbox := openTestOutbox(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer outbox.Close()
+
+	broker := &fakeKafka{missing: "order-events"}
+	err := outbox.Add(
+		kafka.Message{Topic: "order-events", Key: []byte("1"), Value: []byte("order 1")},
+		kafka.Message{Topic: "product-queries", Key: []byte("a"), Value: []byte("a1")},
+		kafka.Message{Topic: "product-queries", Key: []byte("a"), Value: []byte("a2")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox.StartRelay(broker.write)
+	waitForEmptyOutbox(t, outbox)
+
+	if got := broker.deliveredMessages(); len(got) != 2 || got[0] != "a1" || got[1] != "a2" {
+		t.Fatalf("expected the events of the other topic to be relayed, got %v", got)
+	}
+	if outbox.DeadLetters() != 1 {
+		t.Fatalf("expected the refused event in the dead letters, got %d", outbox.DeadLetters())
+	}
+}
+
+func TestOutboxDeadLettersCorruptEvents(t *testing.T) {
+	outbox := openTestOutbox(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer outbox.Close()
+
+	if err := outbox.Add(kafka.Message{Key: []byte("1"), Value: []byte("product 1")}); err != nil {
+		t.Fatal(err)
+	}
+	// Stored ahead of the valid event, which must still be relayed.
+	err := outbox.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(outboxBucket).Put(sequenceKey(0), []byte("not json"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broker := &fakeKafka{}
+	outbox.StartRelay(broker.write)
+	waitForEmptyOutbox(t, outbox)
+
+	if got := broker.deliveredMessages(); len(got) != 1 || got[0] != "product 1" {
+		t.Fatalf("expected the valid event to be relayed, got %v", got)
+	}
+	if outbox.DeadLetters() != 1 {
+		t.Fatalf("expected the corrupt event in the dead letters, got %d", outbox.DeadLetters())
+	}
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
+)
+
+type productCacheEntry struct {
+	products   []models.Product
+	fetchedAt  time.Time
+	refreshing bool
+}
+
+/**
+* productCache keeps the product list returned by the domain API for each product type. An entry is fresh for ttl,
+* after which it is stale: it is still served for up to maxStale while it gets refreshed in the background, so a
+* failing domain API does not break product listings straight away. A ttl of 0 disables the cache.
+ */
+type productCache struct {
+	ttl      time.Duration
+	maxStale time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*productCacheEntry
+	// bumped on every invalidation, so that a fetch started before it does not put outdated products back
+	generation int
+}
+
+func newProductCache(ttl, maxStale time.Duration) *productCache {
+	return &productCache{ttl: ttl, maxStale: maxStale, entries: make(map[string]*productCacheEntry)}
+}
+
+func (c *productCache) enabled() bool {
+	return c.ttl > 0
+}
+
+/**
+* get returns the cached products of the type. ok is false when there is nothing usable in the cache. When the entry
+* is stale, refresh is true for exactly one caller, which is then responsible for refreshing it with put or
+* refreshFailed.
+ */
+func (c *productCache) get(productType string) (products []models.Product, ok bool, refresh bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.entries[productType]
+	if !exists {
+		return nil, false, false
+	}
+
+	age := time.Since(entry.fetchedAt)
+	if age <= c.ttl {
+		return entry.products, true, false
+	}
+
+	if age > c.ttl+c.maxStale {
+		delete(c.entries, productType)
+		return nil, false, false
+	}
+
+	refresh = !entry.refreshing
+	entry.refreshing = true
+	return entry.products, true, refresh
+}
+
+// currentGeneration must be read before fetching the products that are then given to put.
+func (c *productCache) currentGeneration() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+func (c *productCache) put(productType string, products []models.Product, generation int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	c.entries[productType] = &productCacheEntry{products: products, fetchedAt: time.Now()}
+}
+
+// refreshFailed lets a later caller try to refresh the stale entry again.
+func (c *productCache) refreshFailed(productType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, exists := c.entries[productType]; exists {
+		entry.refreshing = false
+	}
+}
+
+func (c *productCache) invalidate(productType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, productType)
+	c.generation++
+}
+
+func (c *productCache) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*productCacheEntry)
+	c.generation++
+}
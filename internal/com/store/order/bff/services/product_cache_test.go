@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/models"
+)
+
+var cachedGadgets = []models.Product{{ID: 1, Name: "iPhone", Type: models.TypeGadget, Inventory: 10}}
+
+// age makes the cached entry of the type look fetched that long ago.
+func (c *productCache) age(productType string, age time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[productType].fetchedAt = time.Now().Add(-age)
+}
+
+func TestProductCacheServesFreshEntries(t *testing.T) {
+	cache := newProductCache(time.Minute, time.Minute)
+	cache.put("gadget", cachedGadgets, cache.currentGeneration())
+
+	products, ok, refresh := cache.get("gadget")
+	if !ok || refresh || len(products) != 1 {
+		t.Fatalf("expected a fresh hit without refresh, got ok=%v refresh=%v %v", ok, refresh, products)
+	}
+	if _, ok, _ := cache.get("book"); ok {
+		t.Fatal("expected a miss for a type never cached")
+	}
+}
+
+func TestProductCacheRefreshesStaleEntriesOnce(t *testing.T) {
+	cache := newProductCache(time.Minute, time.Minute)
+	cache.put("gadget", cachedGadgets, cache.currentGeneration())
+	cache.age("gadget", 90*time.Second)
+
+	if _, ok, refresh := cache.get("gadget"); !ok || !refresh {
+		t.Fatalf("expected a stale hit asking for a refresh, got ok=%v refresh=%v", ok, refresh)
+	}
+	if _, ok, refresh := cache.get("gadget"); !ok || refresh {
+		t.Fatalf("expected a stale hit without a second refresh, got ok=%v refresh=%v", ok, refresh)
+	}
+
+	cache.refreshFailed("gadget")
+	if _, ok, refresh := cache.get("gadget"); !ok || !refresh {
+		t.Fatalf("expected a new refresh after the failed one, got ok=%v refresh=%v", ok, refresh)
+	}
+
+	cache.put("gadget", cachedGadgets, cache.currentGeneration())
+	if _, ok, refresh := cache.get("gadget"); !ok || refresh {
+		t.Fatalf("expected a fresh hit once refreshed, got ok=%v refresh=%v", ok, refresh)
+	}
+}
+
+func TestProductCacheExpiresPastMaxStale(t *testing.T) {
+	cache := newProductCache(time.Minute, time.Minute)
+	cache.put("gadget", cachedGadgets, cache.currentGeneration())
+	cache.age("gadget", 3*time.Minute)
+
+	if _, ok, refresh := cache.get("gadget"); ok || refresh {
+		t.Fatalf("expected a miss past the stale window, got ok=%v refresh=%v", ok, refresh)
+	}
+}
+
+func TestProductCacheDiscardsFetchStartedBeforeInvalidation(t *testing.T) {
+	cache := newProductCache(time.Minute, time.Minute)
+
+	generation := cache.currentGeneration()
+	cache.invalidate("gadget")
+	cache.put("gadget", cachedGadgets, generation)
+
+	if _, ok, _ := cache.get("gadget"); ok {
+		t.Fatal("expected the products fetched before the invalidation not to be cached")
+	}
+}
+
+func TestBackendRefreshesStaleProductsInBackgroundOnce(t *testing.T) {
+	var calls atomic.Int32
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"id":1,"name":"iPhone","type":"gadget","inventory":10}]`))
+	}))
+	defer stub.Close()
+
+	backend, err := NewBackendService(&config.Config{
+		ProductCacheTTL:         time.Minute,
+		ProductCacheMaxStale:    time.Minute,
+		BackendProbeInterval:    time.Hour,
+		BackendRetryMaxAttempts: 1,
+		BackendReadTimeout:      time.Second,
+	}, []string{stub.URL}, StaticToken("API-TOKEN-SPEC"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := backend.getProductsOfType(context.Background(), "gadget"); err != nil {
+		t.Fatal(err)
+	}
+	backend.productCache.age("gadget", 90*time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := backend.getProductsOfType(context.Background(), "gadget"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(time.Second)
+	for calls.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if calls.Load() != 2 {
+		t.Fatalf("expected the initial fetch and a single refresh, got %d calls", calls.Load())
+	}
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+)
+
+// ErrPublishQueueFull is returned with the fail policy when the async publishing queue has no room left.
+var ErrPublishQueueFull = &ServiceError{Kind: ErrUpstreamUnavailable, Reason: "the Kafka publishing queue is full"}
+
+var errPublishQueueClosed = &ServiceError{Kind: ErrUpstreamUnavailable, Reason: "the Kafka producer is shutting down"}
+
+/**
+* publishQueue decouples requests from Kafka in async mode: messages are queued in memory and written by a single
+* worker in batches of up to batchSize, waiting at most linger for a batch to fill. The queue is bounded, and what
+* happens when it is full depends on the policy: block until there is room, drop the message, or fail the request.
+ */
+type publishQueue struct {
+	messages  chan kafka.Message
+	batchSize int
+	linger    time.Duration
+	policy    string
+
+	write   func(ctx context.Context, messages ...kafka.Message) error
+	deliver func(messages []kafka.Message, err error)
+	stats   *publishStats
+
+	// Enqueues hold mu for reading, so that close only lets the worker drain the queue once none is in progress.
+	// sendMu makes checking for room and queuing atomic with the fail policy.
+	mu      sync.RWMutex
+	sendMu  sync.Mutex
+	closed  bool
+	closing chan struct{}
+	stop    chan struct{}
+	done    sync.WaitGroup
+}
+
+func newPublishQueue(cfg *config.Config, write func(ctx context.Context, messages ...kafka.Message) error, deliver func([]kafka.Message, error), stats *publishStats) *publishQueue {
+	q := &publishQueue{
+		messages:  make(chan kafka.Message, cfg.KafkaQueueSize),
+		batchSize: cfg.KafkaBatchSize,
+		linger:    cfg.KafkaLinger,
+		policy:    cfg.KafkaQueueFullPolicy,
+		write:     write,
+		deliver:   deliver,
+		stats:     stats,
+		closing:   make(chan struct{}),
+		stop:      make(chan struct{}),
+	}
+
+	q.done.Add(1)
+	go func() {
+		defer q.done.Done()
+		q.run()
+	}()
+	return q
+}
+
+func (q *publishQueue) enqueue(ctx context.Context, messages []kafka.Message) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return errPublishQueueClosed
+	}
+
+	switch q.policy {
+	case config.QueueFullFail:
+		return q.enqueueAll(messages)
+	case config.QueueFullDrop:
+		for _, message := range messages {
+			q.stats.queued.Add(1)
+			select {
+			case q.messages <- message:
+			default:
+				q.stats.queued.Add(-1)
+				q.stats.dropped.Add(1)
+				log.Printf("Dropping message for key %s, the Kafka publishing queue is full", message.Key)
+			}
+		}
+		return nil
+	default:
+		for _, message := range messages {
+			q.stats.queued.Add(1)
+			select {
+			case q.messages <- message:
+			case <-ctx.Done():
+				q.stats.queued.Add(-1)
+				return ctx.Err()
+			case <-q.closing:
+				q.stats.queued.Add(-1)
+				return errPublishQueueClosed
+			}
+		}
+		return nil
+	}
+}
+
+// enqueueAll queues every message, or none of them when the queue does not have room for all.
+func (q *publishQueue) enqueueAll(messages []kafka.Message) error {
+	q.sendMu.Lock()
+	defer q.sendMu.Unlock()
+
+	// Only the worker takes messages out meanwhile, so the room can only grow.
+	if cap(q.messages)-len(q.messages) < len(messages) {
+		q.stats.rejected.Add(int64(len(messages)))
+		return ErrPublishQueueFull
+	}
+
+	q.stats.queued.Add(int64(len(messages)))
+	for _, message := range messages {
+		q.messages <- message
+	}
+	return nil
+}
+
+func (q *publishQueue) run() {
+	for {
+		var batch []kafka.Message
+		select {
+		case message := <-q.messages:
+			batch = append(batch, message)
+		case <-q.stop:
+			q.drain()
+			return
+		}
+
+		linger := time.NewTimer(q.linger)
+	fill:
+		for len(batch) < q.batchSize {
+			select {
+			case message := <-q.messages:
+				batch = append(batch, message)
+			case <-linger.C:
+				break fill
+			case <-q.closing:
+				break fill
+			}
+		}
+		linger.Stop()
+
+		q.flush(batch)
+	}
+}
+
+// drain writes whatever is still queued, on shutdown.
+func (q *publishQueue) drain() {
+	var batch []kafka.Message
+	for {
+		select {
+		case message := <-q.messages:
+			batch = append(batch, message)
+			if len(batch) < q.batchSize {
+				continue
+			}
+		default:
+			if len(batch) == 0 {
+				return
+			}
+		}
+		q.flush(batch)
+		batch = nil
+	}
+}
+
+func (q *publishQueue) flush(batch []kafka.Message) {
+	q.stats.queued.Add(-int64(len(batch)))
+	q.deliver(batch, q.write(context.Background(), batch...))
+}
+
+// close stops accepting messages and returns once the ones already queued are written.
+func (q *publishQueue) close() {
+	// Enqueues waiting for room give up, and the ones in progress finish before the worker drains the queue.
+	close(q.closing)
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+
+	close(q.stop)
+	q.done.Wait()
+}
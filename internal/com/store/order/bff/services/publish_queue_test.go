@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+)
+
+// newTestPublishQueue returns a queue whose writes block until release is closed, and the sizes of the batches written.
+func newTestPublishQueue(policy string, queueSize int) (*publishQueue, *publishStats, chan struct{}, func() []int) {
+	var mu sync.Mutex
+	var batches []int
+	release := make(chan struct{})
+
+	write := func(_ context.Context, messages ...kafka.Message) error {
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, len(messages))
+		return nil
+	}
+
+	stats := &publishStats{}
+	deliver := func(messages []kafka.Message, err error) {
+		for range messages {
+			stats.record(DeliveryReport{Err: err})
+		}
+	}
+
+	q := newPublishQueue(&config.Config{
+		KafkaQueueSize:       queueSize,
+		KafkaBatchSize:       3,
+		KafkaLinger:          20 * time.Millisecond,
+		KafkaQueueFullPolicy: policy,
+	}, write, deliver, stats)
+
+	return q, stats, release, func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), batches...)
+	}
+}
+
+func TestPublishQueueBatchesMessages(t *testing.T) {
+	q, stats, release, batches := newTestPublishQueue(config.QueueFullBlock, 10)
+	close(release)
+
+	if err := q.enqueue(context.Background(), make([]kafka.Message, 7)); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for stats.delivered.Load() < 7 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	q.close()
+
+	got := batches()
+	if len(got) != 3 || got[0] != 3 || got[1] != 3 || got[2] != 1 {
+		t.Fatalf("expected batches of 3, 3 and 1 messages, got %v", got)
+	}
+	if stats.delivered.Load() != 7 || stats.queued.Load() != 0 {
+		t.Fatalf("expected 7 delivered and none queued, got %+v", stats.snapshot())
+	}
+}
+
+func TestPublishQueueFullPolicies(t *testing.T) {
+	// The worker holds the first batch, once lingered, while the queue fills up behind it.
+	q, stats, release, _ := newTestPublishQueue(config.QueueFullFail, 2)
+	q.enqueue(context.Background(), make([]kafka.Message, 2))
+	time.Sleep(50 * time.Millisecond)
+	q.enqueue(context.Background(), make([]kafka.Message, 2))
+
+	err := q.enqueue(context.Background(), make([]kafka.Message, 2))
+	if !errors.Is(err, ErrPublishQueueFull) || !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Fatalf("expected ErrPublishQueueFull, got %v", err)
+	}
+	if stats.rejected.Load() != 2 {
+		t.Fatalf("expected 2 rejected messages, got %d", stats.rejected.Load())
+	}
+	close(release)
+	q.close()
+
+	// A request is rejected as a whole rather than partly queued.
+	q, stats, release, _ = newTestPublishQueue(config.QueueFullFail, 2)
+	if err := q.enqueue(context.Background(), make([]kafka.Message, 3)); !errors.Is(err, ErrPublishQueueFull) {
+		t.Fatalf("expected ErrPublishQueueFull, got %v", err)
+	}
+	if stats.rejected.Load() != 3 || stats.queued.Load() != 0 {
+		t.Fatalf("expected all 3 messages rejected and none queued, got %+v", stats.snapshot())
+	}
+	close(release)
+	q.close()
+
+	q, stats, release, _ = newTestPublishQueue(config.QueueFullDrop, 2)
+	q.enqueue(context.Background(), make([]kafka.Message, 2))
+	time.Sleep(50 * time.Millisecond)
+	if err := q.enqueue(context.Background(), make([]kafka.Message, 4)); err != nil {
+		t.Fatalf("expected the drop policy not to fail the request, got %v", err)
+	}
+	if stats.dropped.Load() != 2 {
+		t.Fatalf("expected 2 dropped messages, got %d", stats.dropped.Load())
+	}
+	close(release)
+	q.close()
+}
+
+func TestPublishQueueWritesEverythingAcceptedBeforeClose(t *testing.T) {
+	q, stats, release, _ := newTestPublishQueue(config.QueueFullBlock, 5)
+	close(release)
+
+	var accepted atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if q.enqueue(context.Background(), make([]kafka.Message, 1)) == nil {
+					accepted.Add(1)
+				}
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond)
+	q.close()
+	wg.Wait()
+
+	if stats.delivered.Load() != accepted.Load() || stats.queued.Load() != 0 {
+		t.Fatalf("expected the %d accepted messages to be delivered, got %+v", accepted.Load(), stats.snapshot())
+	}
+	if err := q.enqueue(context.Background(), make([]kafka.Message, 1)); !errors.Is(err, errPublishQueueClosed) {
+		t.Fatalf("expected the closed queue to refuse messages, got %v", err)
+	}
+}
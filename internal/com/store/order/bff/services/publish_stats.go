@@ -0,0 +1,43 @@
+package services
+
+import "sync/atomic"
+
+// PublishStats counts the Kafka messages published, by outcome. Queued is the number currently waiting in the async
+// queue, OutboxPending the number waiting in the outbox and OutboxDeadLetters the number it set aside for good.
+type PublishStats struct {
+	Mode              string `json:"mode"`
+	Delivered         int64  `json:"delivered"`
+	Failed            int64  `json:"failed"`
+	Dropped           int64  `json:"dropped"`
+	Rejected          int64  `json:"rejected"`
+	Queued            int64  `json:"queued"`
+	QueueCapacity     int    `json:"queueCapacity,omitempty"`
+	OutboxPending     int    `json:"outboxPending,omitempty"`
+	OutboxDeadLetters int    `json:"outboxDeadLetters,omitempty"`
+}
+
+type publishStats struct {
+	delivered atomic.Int64
+	failed    atomic.Int64
+	dropped   atomic.Int64
+	rejected  atomic.Int64
+	queued    atomic.Int64
+}
+
+func (s *publishStats) record(report DeliveryReport) {
+	if report.Err != nil {
+		s.failed.Add(1)
+		return
+	}
+	s.delivered.Add(1)
+}
+
+func (s *publishStats) snapshot() PublishStats {
+	return PublishStats{
+		Delivered: s.delivered.Load(),
+		Failed:    s.failed.Load(),
+		Dropped:   s.dropped.Load(),
+		Rejected:  s.rejected.Load(),
+		Queued:    s.queued.Load(),
+	}
+}
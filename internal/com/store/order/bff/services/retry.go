@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+)
+
+type RetryPolicy struct {
+	MaxAttempts          int
+	InitialBackoff       time.Duration
+	MaxBackoff           time.Duration
+	Jitter               float64
+	RetryableStatusCodes map[int]bool
+}
+
+func NewRetryPolicy(cfg *config.Config) RetryPolicy {
+	retryableStatusCodes := make(map[int]bool, len(cfg.BackendRetryableStatusCodes))
+	for _, statusCode := range cfg.BackendRetryableStatusCodes {
+		retryableStatusCodes[statusCode] = true
+	}
+
+	return RetryPolicy{
+		MaxAttempts:          max(cfg.BackendRetryMaxAttempts, 1),
+		InitialBackoff:       cfg.BackendRetryInitialBackoff,
+		MaxBackoff:           cfg.BackendRetryMaxBackoff,
+		Jitter:               min(max(cfg.BackendRetryJitter, 0), 1),
+		RetryableStatusCodes: retryableStatusCodes,
+	}
+}
+
+/**
+* Backoff before the given retry (1 for the first retry). The delay doubles on every retry up to MaxBackoff, then up to
+* Jitter (a fraction between 0 and 1) of it is randomly taken off so that clients retrying together spread out.
+ */
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < retry && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxBackoff)
+
+	return delay - time.Duration(p.Jitter*rand.Float64()*float64(delay))
+}
+
+// canRetry tells if the request may be sent again. POST is only retried when the client sent an idempotency key.
+func (p RetryPolicy) canRetry(ctx context.Context, method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return IdempotencyKeyFrom(ctx) != ""
+	default:
+		return false
+	}
+}
+
+// wait sleeps for the backoff of the given retry, returning early with the context error if ctx is done first.
+func (p RetryPolicy) wait(ctx context.Context, retry int) error {
+	timer := time.NewTimer(p.backoff(retry))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/znsio/specmatic-order-bff-go/internal/com/store/order/bff/config"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	tests := []struct {
+		retry int
+		want  time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{20, time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := policy.backoff(tt.retry); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.retry, got, tt.want)
+		}
+	}
+}
+
+func TestRetryBackoffJitter(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		if got := policy.backoff(3); got < 200*time.Millisecond || got > 400*time.Millisecond {
+			t.Fatalf("expected a backoff between 200ms and 400ms, got %v", got)
+		}
+	}
+}
+
+func TestRetryCanRetry(t *testing.T) {
+	policy := RetryPolicy{}
+	withKey := WithIdempotencyKey(context.Background(), "key-1")
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		method string
+		want   bool
+	}{
+		{"GET", context.Background(), http.MethodGet, true},
+		{"PUT", context.Background(), http.MethodPut, true},
+		{"DELETE", context.Background(), http.MethodDelete, true},
+		{"POST without idempotency key", context.Background(), http.MethodPost, false},
+		{"POST with idempotency key", withKey, http.MethodPost, true},
+		{"PATCH", withKey, http.MethodPatch, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.canRetry(tt.ctx, tt.method); got != tt.want {
+				t.Fatalf("canRetry = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newFlakyBackend returns a backend whose domain API answers 503 to the first request and 200 afterwards.
+func newFlakyBackend(t *testing.T) (*BackendService, *atomic.Int32) {
+	var calls atomic.Int32
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1,"name":"iPhone","type":"gadget","inventory":10}`))
+	}))
+	t.Cleanup(stub.Close)
+
+	backend, err := NewBackendService(&config.Config{
+		BackendProbeInterval:           time.Hour,
+		BackendRetryMaxAttempts:        3,
+		BackendRetryInitialBackoff:     time.Millisecond,
+		BackendRetryMaxBackoff:         10 * time.Millisecond,
+		BackendRetryableStatusCodes:    []int{http.StatusServiceUnavailable},
+		BackendBreakerFailureThreshold: 10,
+		BackendReadTimeout:             time.Second,
+		BackendWriteTimeout:            time.Second,
+	}, []string{stub.URL}, StaticToken("API-TOKEN-SPEC"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend, &calls
+}
+
+func TestBackendRetriesRetryableStatus(t *testing.T) {
+	backend, calls := newFlakyBackend(t)
+
+	product, err := backend.GetProduct(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	if product.Name != "iPhone" || calls.Load() != 2 {
+		t.Fatalf("expected iPhone after 2 calls, got %+v after %d", product, calls.Load())
+	}
+}
+
+func TestBackendDoesNotRetryPostWithoutIdempotencyKey(t *testing.T) {
+	backend, calls := newFlakyBackend(t)
+
+	resp, err := backend.do(context.Background(), "createProduct", http.MethodPost, "/products", map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Fatalf("expected a single 503, got %d after %d calls", resp.StatusCode, calls.Load())
+	}
+}
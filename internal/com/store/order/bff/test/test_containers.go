@@ -239,6 +239,34 @@ func RunTestContainer(env *TestEnvironment) (string, error) {
 	return buf.String(), nil
 }
 
+/**
+* ProductMessagesPerListing asks the domain stub for the products of the type the BFF lists, and returns how many
+* Kafka messages the BFF publishes for one listing of them: one per product, up to KAFKA_MAX_PRODUCT_MESSAGES.
+ */
+func ProductMessagesPerListing(env *TestEnvironment, productType string) (int, error) {
+	host, err := env.DomainServiceContainer.Host(env.Ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := resty.New().R().
+		SetHeader("Authenticate", env.Config.BackendAuthToken).
+		SetQueryParam("type", productType).
+		Get(fmt.Sprintf("http://%s:%s/products", host, env.DomainServiceDynamicPort))
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode() != 200 {
+		return 0, fmt.Errorf("domain stub answered %s to the %s products", resp.Status(), productType)
+	}
+
+	products := len(gjson.ParseBytes(resp.Body()).Array())
+	if products > env.Config.KafkaMaxProductMessages {
+		products = env.Config.KafkaMaxProductMessages
+	}
+	return products, nil
+}
+
 // func SetKafkaExpectations(env *TestEnvironment) error {
 // 	endpoint := "/_expectations"
 // 	url := fmt.Sprintf("http://%s:%s%s", env.KafkaAPIHost, env.KafkaDynamicAPIPort, endpoint)
@@ -272,6 +300,33 @@ func SetKafkaExpectations(env *TestEnvironment) error {
 	return err
 }
 
+/**
+* WaitForOutbox waits until the BFF has relayed every event of its outbox to Kafka, as reported on /status/publishing,
+* so that the Kafka expectations are not verified while messages are still on their way.
+ */
+func WaitForOutbox(env *TestEnvironment, timeout time.Duration) error {
+	host, err := env.BffServiceContainer.Host(env.Ctx)
+	if err != nil {
+		return err
+	}
+
+	client := resty.New()
+	deadline := time.Now().Add(timeout)
+	for {
+		resp, err := client.R().Get(fmt.Sprintf("http://%s:%s/status/publishing", host, env.BffServiceDynamicPort))
+		if err == nil && resp.StatusCode() == 200 && gjson.GetBytes(resp.Body(), "outboxPending").Int() == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("outbox not drained after %v: %s", timeout, resp.Body())
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
 func VerifyKafkaExpectations(env *TestEnvironment) error {
 	client := resty.New()
 
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,3 +15,20 @@ func ErrorResponse(c *gin.Context, statusCode int, message string) {
 		"timestamp": time.Now().Format(time.RFC3339),
 	})
 }
+
+// FieldError points at the invalid part of a request, e.g. {In: "header", Name: "pageSize"}.
+type FieldError struct {
+	In     string `json:"in"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func ValidationErrorResponse(c *gin.Context, message string, fieldErrors []FieldError) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":     message,
+		"status":    http.StatusBadRequest,
+		"message":   message,
+		"errors":    fieldErrors,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}